@@ -0,0 +1,192 @@
+package journal
+
+import (
+	"context"
+	"github.com/integration-system/isp-journal/codes"
+	"github.com/integration-system/isp-journal/entry"
+	log "github.com/integration-system/isp-log"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	defaultQueueSize = 1024
+)
+
+type OverflowPolicy string
+
+const (
+	// OverflowBlock blocks caller until queue has free space
+	OverflowBlock OverflowPolicy = "block"
+	// OverflowDropNewest discards entry which is being logged
+	OverflowDropNewest OverflowPolicy = "drop_newest"
+	// OverflowDropOldest discards the oldest queued entry to make room for a new one
+	OverflowDropOldest OverflowPolicy = "drop_oldest"
+)
+
+type AsyncConfig struct {
+	QueueSize int            `schema:"Размер очереди,максимальное количество записей ожидающих записи в журнал"`
+	Overflow  OverflowPolicy `schema:"Политика переполнения,block - ожидать освобождения очереди, drop_newest - отбрасывать новые записи, drop_oldest - отбрасывать старые записи"`
+}
+
+type asyncRecord struct {
+	ctx   context.Context
+	level entry.Level
+	event string
+	req   []byte
+	res   []byte
+	err   error
+}
+
+// AsyncJournal writes entries to underlying journal in background goroutine.
+// Request and response slices must not be modified after Log call.
+type AsyncJournal struct {
+	journal Journal
+	policy  OverflowPolicy
+	queue   chan asyncRecord
+	done    chan struct{}
+
+	closeLock sync.RWMutex
+	closed    bool
+
+	dropped int64
+	failed  int64
+}
+
+func (j *AsyncJournal) Log(level entry.Level, event string, req []byte, res []byte, err error) error {
+	return j.LogCtx(context.Background(), level, event, req, res, err)
+}
+
+func (j *AsyncJournal) LogCtx(ctx context.Context, level entry.Level, event string, req []byte, res []byte, err error) error {
+	j.closeLock.RLock()
+	defer j.closeLock.RUnlock()
+
+	if j.closed {
+		return ErrJournalClosed
+	}
+
+	r := asyncRecord{
+		ctx:   withTime(ctx, entryTime(ctx)),
+		level: level,
+		event: event,
+		req:   req,
+		res:   res,
+		err:   err,
+	}
+
+	switch j.policy {
+	case OverflowDropNewest:
+		select {
+		case j.queue <- r:
+		default:
+			atomic.AddInt64(&j.dropped, 1)
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case j.queue <- r:
+				return nil
+			default:
+			}
+			select {
+			case <-j.queue:
+				atomic.AddInt64(&j.dropped, 1)
+			default:
+			}
+		}
+	default:
+		j.queue <- r
+	}
+
+	return nil
+}
+
+func (j *AsyncJournal) Info(event string, req []byte, res []byte) error {
+	return j.Log(entry.LevelInfo, event, req, res, nil)
+}
+
+func (j *AsyncJournal) Warn(event string, req []byte, res []byte, err error) error {
+	return j.Log(entry.LevelWarn, event, req, res, err)
+}
+
+func (j *AsyncJournal) Error(event string, req []byte, res []byte, err error) error {
+	return j.Log(entry.LevelError, event, req, res, err)
+}
+
+func (j *AsyncJournal) InfoCtx(ctx context.Context, event string, req []byte, res []byte) error {
+	return j.LogCtx(ctx, entry.LevelInfo, event, req, res, nil)
+}
+
+func (j *AsyncJournal) WarnCtx(ctx context.Context, event string, req []byte, res []byte, err error) error {
+	return j.LogCtx(ctx, entry.LevelWarn, event, req, res, err)
+}
+
+func (j *AsyncJournal) ErrorCtx(ctx context.Context, event string, req []byte, res []byte, err error) error {
+	return j.LogCtx(ctx, entry.LevelError, event, req, res, err)
+}
+
+func (j *AsyncJournal) Rotate() error {
+	return j.journal.Rotate()
+}
+
+// Close waits until all queued entries are written and closes underlying journal
+func (j *AsyncJournal) Close() error {
+	j.closeLock.Lock()
+	if j.closed {
+		j.closeLock.Unlock()
+		return ErrJournalClosed
+	}
+	j.closed = true
+	close(j.queue)
+	j.closeLock.Unlock()
+
+	<-j.done
+	return j.journal.Close()
+}
+
+// Dropped returns count of entries discarded due to queue overflow
+func (j *AsyncJournal) Dropped() int64 {
+	return atomic.LoadInt64(&j.dropped)
+}
+
+// Failed returns count of entries which underlying journal could not write
+func (j *AsyncJournal) Failed() int64 {
+	return atomic.LoadInt64(&j.failed)
+}
+
+// Queued returns count of entries waiting to be written
+func (j *AsyncJournal) Queued() int {
+	return len(j.queue)
+}
+
+func (j *AsyncJournal) run() {
+	defer close(j.done)
+
+	for r := range j.queue {
+		if err := j.journal.LogCtx(r.ctx, r.level, r.event, r.req, r.res, r.err); err != nil {
+			atomic.AddInt64(&j.failed, 1)
+			log.Warnf(codes.JournalingError, "could not write to journal: %v", err)
+		}
+	}
+}
+
+func NewAsyncJournal(journal Journal, config AsyncConfig) *AsyncJournal {
+	queueSize := config.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	policy := config.Overflow
+	if policy == "" {
+		policy = OverflowBlock
+	}
+
+	j := &AsyncJournal{
+		journal: journal,
+		policy:  policy,
+		queue:   make(chan asyncRecord, queueSize),
+		done:    make(chan struct{}),
+	}
+	go j.run()
+
+	return j
+}
@@ -0,0 +1,67 @@
+package journal
+
+import (
+	"context"
+	"github.com/integration-system/isp-journal/entry"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+type stubJournal struct {
+	Journal
+	lock    sync.Mutex
+	events  []string
+	release chan struct{}
+	closed  bool
+}
+
+func (j *stubJournal) LogCtx(ctx context.Context, level entry.Level, event string, req []byte, res []byte, err error) error {
+	if j.release != nil {
+		<-j.release
+	}
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.events = append(j.events, event)
+	return nil
+}
+
+func (j *stubJournal) Close() error {
+	j.closed = true
+	return nil
+}
+
+func TestAsyncJournalDrainsQueueOnClose(t *testing.T) {
+	a := assert.New(t)
+
+	stub := &stubJournal{}
+	j := NewAsyncJournal(stub, AsyncConfig{QueueSize: 10})
+	for i := 0; i < 100; i++ {
+		a.NoError(j.Info("event", nil, nil))
+	}
+	a.NoError(j.Close())
+
+	a.Len(stub.events, 100)
+	a.True(stub.closed)
+	a.EqualValues(0, j.Dropped())
+	a.Equal(ErrJournalClosed, j.Info("event", nil, nil))
+}
+
+func TestAsyncJournalOverflow(t *testing.T) {
+	a := assert.New(t)
+
+	stub := &stubJournal{release: make(chan struct{})}
+	j := NewAsyncJournal(stub, AsyncConfig{QueueSize: 2, Overflow: OverflowDropOldest})
+	// first entry is taken by worker and blocks it
+	a.NoError(j.Info("0", nil, nil))
+	for len(j.queue) != 0 {
+	}
+	for _, event := range []string{"1", "2", "3", "4"} {
+		a.NoError(j.Info(event, nil, nil))
+	}
+	close(stub.release)
+	a.NoError(j.Close())
+
+	a.Equal([]string{"0", "3", "4"}, stub.events)
+	a.EqualValues(2, j.Dropped())
+}
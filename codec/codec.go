@@ -0,0 +1,157 @@
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// Codec is compression format of log files
+type Codec string
+
+const (
+	// Unknown codec is detected by magic bytes
+	Unknown Codec = ""
+	None    Codec = "none"
+	Gzip    Codec = "gzip"
+	Zstd    Codec = "zstd"
+	Snappy  Codec = "snappy"
+	Lz4     Codec = "lz4"
+)
+
+// MagicLen is enough length of data head to detect any codec
+const MagicLen = 10
+
+type codecInfo struct {
+	extension   string
+	contentType string
+	magic       []byte
+}
+
+var (
+	codecs = map[Codec]codecInfo{
+		None:   {extension: "", contentType: "application/binary"},
+		Gzip:   {extension: ".gz", contentType: "application/gzip", magic: []byte{0x1f, 0x8b}},
+		Zstd:   {extension: ".zst", contentType: "application/zstd", magic: []byte{0x28, 0xb5, 0x2f, 0xfd}},
+		Snappy: {extension: ".sz", contentType: "application/x-snappy-framed", magic: []byte("\xff\x06\x00\x00sNaPpY")},
+		Lz4:    {extension: ".lz4", contentType: "application/x-lz4", magic: []byte{0x04, 0x22, 0x4d, 0x18}},
+	}
+)
+
+// Extension returns file name extension of codec, it is empty for None
+func (c Codec) Extension() string {
+	return codecs[c].extension
+}
+
+func (c Codec) ContentType() string {
+	if info, ok := codecs[c]; ok {
+		return info.contentType
+	}
+	return codecs[None].contentType
+}
+
+// Parse parses codec name ignoring case, empty name means None
+func Parse(s string) (Codec, error) {
+	name := Codec(strings.ToLower(strings.TrimSpace(s)))
+	if name == "" {
+		return None, nil
+	}
+	if _, ok := codecs[name]; !ok {
+		return None, fmt.Errorf("unknown codec '%s'", s)
+	}
+	return name, nil
+}
+
+// ByExtension returns None for unknown extensions
+func ByExtension(ext string) Codec {
+	for c, info := range codecs {
+		if info.extension != "" && info.extension == ext {
+			return c
+		}
+	}
+	return None
+}
+
+// ByContentType returns None for unknown content types
+func ByContentType(contentType string) Codec {
+	for c, info := range codecs {
+		if info.contentType == contentType {
+			return c
+		}
+	}
+	return None
+}
+
+// Detect returns codec by magic bytes at the head of data, None if data is not compressed
+func Detect(head []byte) Codec {
+	for c, info := range codecs {
+		if len(info.magic) > 0 && bytes.HasPrefix(head, info.magic) {
+			return c
+		}
+	}
+	return None
+}
+
+// NewWriter returns compressing writer, level 0 means default level of codec.
+// Gzip levels are 1-9, zstd levels are 1-22, lz4 levels are positive with higher is better, snappy has no levels.
+func NewWriter(c Codec, w io.Writer, level int) (io.WriteCloser, error) {
+	switch c {
+	case None:
+		return nopWriteCloser{w}, nil
+	case Gzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	case Zstd:
+		opts := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+		if level != 0 {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		return zstd.NewWriter(w, opts...)
+	case Snappy:
+		return snappy.NewBufferedWriter(w), nil
+	case Lz4:
+		lw := lz4.NewWriter(w)
+		lw.Header.CompressionLevel = level
+		return lw, nil
+	default:
+		return nil, fmt.Errorf("unknown codec '%s'", c)
+	}
+}
+
+// NewReader returns decompressing reader, concatenated streams are read one by one
+func NewReader(c Codec, r io.Reader) (io.ReadCloser, error) {
+	switch c {
+	case None:
+		return ioutil.NopCloser(r), nil
+	case Gzip:
+		return gzip.NewReader(r)
+	case Zstd:
+		d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	case Snappy:
+		return ioutil.NopCloser(snappy.NewReader(r)), nil
+	case Lz4:
+		return ioutil.NopCloser(lz4.NewReader(r)), nil
+	default:
+		return nil, fmt.Errorf("unknown codec '%s'", c)
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
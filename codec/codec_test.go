@@ -0,0 +1,52 @@
+package codec
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func TestCodecs(t *testing.T) {
+	a := assert.New(t)
+
+	data := bytes.Repeat([]byte("journal entry "), 1000)
+	for _, c := range []Codec{None, Gzip, Zstd, Snappy, Lz4} {
+		buf := bytes.Buffer{}
+		// appended file contains two streams
+		for _, part := range [][]byte{data[:5000], data[5000:]} {
+			w, err := NewWriter(c, &buf, 3)
+			a.NoError(err, c)
+			_, err = w.Write(part)
+			a.NoError(err, c)
+			a.NoError(w.Close(), c)
+		}
+		a.Equal(c, Detect(buf.Bytes()), c)
+
+		r, err := NewReader(c, bytes.NewReader(buf.Bytes()))
+		a.NoError(err, c)
+		decoded, err := ioutil.ReadAll(r)
+		a.NoError(err, c)
+		a.Equal(data, decoded, c)
+		a.NoError(r.Close(), c)
+	}
+}
+
+func TestParse(t *testing.T) {
+	a := assert.New(t)
+
+	c, err := Parse(" ZSTD")
+	a.NoError(err)
+	a.Equal(Zstd, c)
+	a.Equal(".zst", c.Extension())
+	a.Equal(Zstd, ByExtension(".zst"))
+	a.Equal(Zstd, ByContentType(c.ContentType()))
+
+	c, err = Parse("")
+	a.NoError(err)
+	a.Equal(None, c)
+	a.Equal("", c.Extension())
+
+	_, err = Parse("brotli")
+	a.Error(err)
+}
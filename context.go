@@ -0,0 +1,159 @@
+package journal
+
+import (
+	"context"
+	"google.golang.org/grpc/metadata"
+	"strings"
+	"time"
+)
+
+const (
+	TraceIdMetadataKey     = "x-trace-id"
+	SpanIdMetadataKey      = "x-span-id"
+	RequestIdMetadataKey   = "x-request-id"
+	TraceParentMetadataKey = "traceparent"
+)
+
+type ctxKey int
+
+const (
+	traceIdKey ctxKey = iota
+	spanIdKey
+	requestIdKey
+	labelsKey
+	durationKey
+	timeKey
+	payloadLengthsKey
+)
+
+func WithTraceId(ctx context.Context, traceId string) context.Context {
+	return context.WithValue(ctx, traceIdKey, traceId)
+}
+
+func WithSpanId(ctx context.Context, spanId string) context.Context {
+	return context.WithValue(ctx, spanIdKey, spanId)
+}
+
+func WithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdKey, requestId)
+}
+
+// TraceIdFromContext returns trace id stored with WithTraceId,
+// otherwise looks for it in incoming grpc metadata
+func TraceIdFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(traceIdKey).(string); ok && id != "" {
+		return id
+	}
+	if id := metadataValue(ctx, TraceIdMetadataKey); id != "" {
+		return id
+	}
+	traceId, _ := parseTraceParent(metadataValue(ctx, TraceParentMetadataKey))
+	return traceId
+}
+
+// SpanIdFromContext returns span id stored with WithSpanId,
+// otherwise looks for it in incoming grpc metadata
+func SpanIdFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(spanIdKey).(string); ok && id != "" {
+		return id
+	}
+	if id := metadataValue(ctx, SpanIdMetadataKey); id != "" {
+		return id
+	}
+	_, spanId := parseTraceParent(metadataValue(ctx, TraceParentMetadataKey))
+	return spanId
+}
+
+// RequestIdFromContext returns request id stored with WithRequestId,
+// otherwise looks for it in incoming grpc metadata
+func RequestIdFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIdKey).(string); ok && id != "" {
+		return id
+	}
+	return metadataValue(ctx, RequestIdMetadataKey)
+}
+
+// WithLabels returns context with labels merged over labels of parent context
+func WithLabels(ctx context.Context, labels map[string]string) context.Context {
+	parent := LabelsFromContext(ctx)
+	merged := make(map[string]string, len(parent)+len(labels))
+	for k, v := range parent {
+		merged[k] = v
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, labelsKey, merged)
+}
+
+func WithLabel(ctx context.Context, key, value string) context.Context {
+	return WithLabels(ctx, map[string]string{key: value})
+}
+
+func LabelsFromContext(ctx context.Context) map[string]string {
+	labels, _ := ctx.Value(labelsKey).(map[string]string)
+	return labels
+}
+
+func WithDuration(ctx context.Context, duration time.Duration) context.Context {
+	return context.WithValue(ctx, durationKey, duration)
+}
+
+func DurationFromContext(ctx context.Context) time.Duration {
+	duration, _ := ctx.Value(durationKey).(time.Duration)
+	return duration
+}
+
+// WithPayloadLengths sets original lengths of request and response which were cut by caller,
+// entry is marked as truncated if passed payloads are shorter
+func WithPayloadLengths(ctx context.Context, requestLength, responseLength int) context.Context {
+	return context.WithValue(ctx, payloadLengthsKey, [2]int{requestLength, responseLength})
+}
+
+// payloadLengths returns original lengths of payloads, they are not less than lengths of passed payloads
+func payloadLengths(ctx context.Context, req []byte, res []byte) (int, int) {
+	lengths, _ := ctx.Value(payloadLengthsKey).([2]int)
+	reqLen, resLen := len(req), len(res)
+	if lengths[0] > reqLen {
+		reqLen = lengths[0]
+	}
+	if lengths[1] > resLen {
+		resLen = lengths[1]
+	}
+	return reqLen, resLen
+}
+
+func withTime(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, timeKey, t)
+}
+
+// entryTime returns time when entry was actually logged, it may differ from
+// current time if entry was written asynchronously
+func entryTime(ctx context.Context) time.Time {
+	if t, ok := ctx.Value(timeKey).(time.Time); ok {
+		return t
+	}
+	return time.Now()
+}
+
+func metadataValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := md.Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// parseTraceParent extracts trace and parent span ids from w3c 'traceparent' header
+// version-traceid-parentid-flags
+func parseTraceParent(value string) (string, string) {
+	parts := strings.Split(value, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", ""
+	}
+	return parts[1], parts[2]
+}
@@ -0,0 +1,114 @@
+package journal
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+	"testing"
+)
+
+func TestIdsFromContext(t *testing.T) {
+	a := assert.New(t)
+
+	const (
+		traceId = "0af7651916cd43dd8448eb211c80319c"
+		spanId  = "b7ad6b7169203331"
+	)
+	traceParent := "00-" + traceId + "-" + spanId + "-01"
+
+	cases := []struct {
+		name      string
+		ctx       context.Context
+		traceId   string
+		spanId    string
+		requestId string
+	}{
+		{
+			name: "empty context",
+			ctx:  context.Background(),
+		},
+		{
+			name:      "explicit ids",
+			ctx:       WithRequestId(WithSpanId(WithTraceId(context.Background(), "trace"), "span"), "request"),
+			traceId:   "trace",
+			spanId:    "span",
+			requestId: "request",
+		},
+		{
+			name: "metadata",
+			ctx: metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+				TraceIdMetadataKey, "trace", SpanIdMetadataKey, "span", RequestIdMetadataKey, "request",
+			)),
+			traceId:   "trace",
+			spanId:    "span",
+			requestId: "request",
+		},
+		{
+			name: "explicit ids take precedence over metadata",
+			ctx: WithRequestId(WithSpanId(WithTraceId(metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+				TraceIdMetadataKey, "md-trace", SpanIdMetadataKey, "md-span", RequestIdMetadataKey, "md-request",
+				TraceParentMetadataKey, traceParent,
+			)), "trace"), "span"), "request"),
+			traceId:   "trace",
+			spanId:    "span",
+			requestId: "request",
+		},
+		{
+			name: "empty explicit ids fall back to metadata",
+			ctx: WithRequestId(WithTraceId(metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+				TraceIdMetadataKey, "md-trace", RequestIdMetadataKey, "md-request",
+			)), ""), ""),
+			traceId:   "md-trace",
+			requestId: "md-request",
+		},
+		{
+			name:    "traceparent",
+			ctx:     metadata.NewIncomingContext(context.Background(), metadata.Pairs(TraceParentMetadataKey, traceParent)),
+			traceId: traceId,
+			spanId:  spanId,
+		},
+		{
+			name: "id metadata take precedence over traceparent",
+			ctx: metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+				TraceParentMetadataKey, traceParent, TraceIdMetadataKey, "trace",
+			)),
+			traceId: "trace",
+			spanId:  spanId,
+		},
+		{
+			name: "malformed traceparent",
+			ctx:  metadata.NewIncomingContext(context.Background(), metadata.Pairs(TraceParentMetadataKey, "garbage")),
+		},
+	}
+	for _, c := range cases {
+		a.Equal(c.traceId, TraceIdFromContext(c.ctx), c.name)
+		a.Equal(c.spanId, SpanIdFromContext(c.ctx), c.name)
+		a.Equal(c.requestId, RequestIdFromContext(c.ctx), c.name)
+	}
+}
+
+func TestParseTraceParent(t *testing.T) {
+	a := assert.New(t)
+
+	cases := []struct {
+		value   string
+		traceId string
+		spanId  string
+	}{
+		{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", "0af7651916cd43dd8448eb211c80319c", "b7ad6b7169203331"},
+		{"", "", ""},
+		{"garbage", "", ""},
+		// short trace id
+		{"00-0af7651916cd43dd-b7ad6b7169203331-01", "", ""},
+		// short span id
+		{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b71-01", "", ""},
+		// flags are missing
+		{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331", "", ""},
+		{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-extra", "", ""},
+	}
+	for _, c := range cases {
+		traceId, spanId := parseTraceParent(c.value)
+		a.Equal(c.traceId, traceId, c.value)
+		a.Equal(c.spanId, spanId, c.value)
+	}
+}
@@ -0,0 +1,31 @@
+package journal
+
+import (
+	"github.com/integration-system/isp-journal/codes"
+	"github.com/integration-system/isp-journal/entry"
+	"github.com/integration-system/isp-journal/log"
+	logger "github.com/integration-system/isp-log"
+	"sync/atomic"
+)
+
+// degradeDrops reports whether entry is dropped in current degraded mode
+func (j *fileJournal) degradeDrops(level entry.Level) bool {
+	mode := j.guard.Mode()
+	if mode == log.DegradeStop || (mode == log.DegradeDropNonError && !level.AtLeast(entry.LevelError)) {
+		atomic.AddInt64(&j.degradeDropped, 1)
+		return true
+	}
+	return false
+}
+
+func (j *fileJournal) onDiskSpaceEvent(event log.DiskSpaceEvent) {
+	freeMb := event.FreeBytes / 1024 / 1024
+	if event.Mode == log.DegradeNone {
+		logger.Infof(codes.JournalingError, "journal returned to normal mode from '%s', free space %d MB", event.PrevMode, freeMb)
+	} else {
+		logger.Warnf(codes.JournalingError, "journal switched to degraded mode '%s', free space %d MB", event.Mode, freeMb)
+	}
+	if j.diskSpaceEvents != nil {
+		j.diskSpaceEvents(event)
+	}
+}
@@ -0,0 +1,270 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Stream format:
+//   header: magic (8 bytes) | key fingerprint (4 bytes) | nonce prefix (8 bytes)
+//   chunks: length of sealed chunk with last chunk flag in the high bit (4 bytes LE) | sealed chunk
+// Nonce of chunk is nonce prefix followed by big endian chunk number, last chunk flag is
+// authenticated as additional data, so truncation of stream is detected.
+// Several streams may follow each other, as it happens when encrypted file is appended.
+
+const (
+	DefaultChunkSize = 64 * 1024
+	// MaxChunkSize limits memory allocated by reader for chunk length read from stream
+	MaxChunkSize = 1024 * 1024
+
+	fingerprintLen = 4
+	noncePrefixLen = 8
+	headerLen      = 8 + fingerprintLen + noncePrefixLen
+	lastChunkFlag  = 1 << 31
+)
+
+var (
+	Magic = []byte("ISPJENC1")
+
+	ErrWrongKey  = errors.New("encryption: stream was encrypted with other key")
+	ErrTruncated = errors.New("encryption: stream is truncated")
+	ErrNoKey     = errors.New("encryption: stream is encrypted, but key is not specified")
+	ErrCorrupted = errors.New("encryption: stream is corrupted")
+)
+
+// IsEncrypted reports whether data starts with encrypted stream header
+func IsEncrypted(head []byte) bool {
+	return bytes.HasPrefix(head, Magic)
+}
+
+func fingerprint(key []byte) []byte {
+	sum := sha256.Sum256(key)
+	return sum[:fingerprintLen]
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("encryption: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(prefix []byte, n uint32) []byte {
+	nonce := make([]byte, noncePrefixLen+4)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[noncePrefixLen:], n)
+	return nonce
+}
+
+func additionalData(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+type Writer struct {
+	w           io.Writer
+	aead        cipher.AEAD
+	key         []byte
+	noncePrefix []byte
+	chunk       []byte
+	chunkSize   int
+	n           uint32
+	wroteHeader bool
+	closed      bool
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("encryption: write to closed writer")
+	}
+	written := 0
+	for len(p) > 0 {
+		free := w.chunkSize - len(w.chunk)
+		if free > len(p) {
+			free = len(p)
+		}
+		w.chunk = append(w.chunk, p[:free]...)
+		p = p[free:]
+		written += free
+		if len(w.chunk) == w.chunkSize {
+			if err := w.seal(false); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Flush writes buffered data as separate chunk
+func (w *Writer) Flush() error {
+	if w.closed || len(w.chunk) == 0 {
+		return nil
+	}
+	return w.seal(false)
+}
+
+// Close writes the last chunk, it doesn't close underlying writer
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.seal(true)
+}
+
+func (w *Writer) seal(last bool) error {
+	if !w.wroteHeader {
+		header := make([]byte, 0, headerLen)
+		header = append(header, Magic...)
+		header = append(header, fingerprint(w.key)...)
+		header = append(header, w.noncePrefix...)
+		if _, err := w.w.Write(header); err != nil {
+			return err
+		}
+		w.wroteHeader = true
+	}
+
+	sealed := w.aead.Seal(make([]byte, 4, 4+len(w.chunk)+w.aead.Overhead()), chunkNonce(w.noncePrefix, w.n), w.chunk, additionalData(last))
+	l := uint32(len(sealed) - 4)
+	if last {
+		l |= lastChunkFlag
+	}
+	binary.LittleEndian.PutUint32(sealed, l)
+	w.n++
+	w.chunk = w.chunk[:0]
+
+	_, err := w.w.Write(sealed)
+	return err
+}
+
+// NewWriter returns writer encrypting data with AES-GCM, key must be 16, 24 or 32 bytes long
+func NewWriter(w io.Writer, key []byte) (*Writer, error) {
+	return NewWriterSize(w, key, DefaultChunkSize)
+}
+
+// NewWriterSize returns writer with specified size of plain chunk, it is at most MaxChunkSize
+func NewWriterSize(w io.Writer, key []byte, chunkSize int) (*Writer, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	if chunkSize > MaxChunkSize {
+		chunkSize = MaxChunkSize
+	}
+	noncePrefix := make([]byte, noncePrefixLen)
+	if _, err := rand.Read(noncePrefix); err != nil {
+		return nil, err
+	}
+	return &Writer{
+		w:           w,
+		aead:        aead,
+		key:         key,
+		noncePrefix: noncePrefix,
+		chunk:       make([]byte, 0, chunkSize),
+		chunkSize:   chunkSize,
+	}, nil
+}
+
+type Reader struct {
+	r           *bufio.Reader
+	aead        cipher.AEAD
+	key         []byte
+	noncePrefix []byte
+	n           uint32
+	plain       []byte
+	// inStream is false before header and after the last chunk of stream
+	inStream bool
+	err      error
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.err = r.next()
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+func (r *Reader) next() error {
+	if !r.inStream {
+		if _, err := r.r.Peek(1); err == io.EOF {
+			return io.EOF
+		}
+		if err := r.readHeader(); err != nil {
+			return err
+		}
+	}
+
+	lenBytes := make([]byte, 4)
+	if _, err := io.ReadFull(r.r, lenBytes); err != nil {
+		return ErrTruncated
+	}
+	l := binary.LittleEndian.Uint32(lenBytes)
+	last := l&lastChunkFlag != 0
+	l &^= lastChunkFlag
+	if l < uint32(r.aead.Overhead()) || l > uint32(MaxChunkSize+r.aead.Overhead()) {
+		return ErrCorrupted
+	}
+	sealed := make([]byte, l)
+	if _, err := io.ReadFull(r.r, sealed); err != nil {
+		return ErrTruncated
+	}
+	plain, err := r.aead.Open(sealed[:0], chunkNonce(r.noncePrefix, r.n), sealed, additionalData(last))
+	if err != nil {
+		return fmt.Errorf("encryption: chunk %d: %v", r.n, err)
+	}
+	r.n++
+	r.plain = plain
+	if last {
+		r.inStream = false
+	}
+	return nil
+}
+
+func (r *Reader) readHeader() error {
+	header := make([]byte, headerLen)
+	if _, err := io.ReadFull(r.r, header); err != nil {
+		return ErrTruncated
+	}
+	if !IsEncrypted(header) {
+		return errors.New("encryption: invalid stream header")
+	}
+	if !bytes.Equal(header[len(Magic):len(Magic)+fingerprintLen], fingerprint(r.key)) {
+		return ErrWrongKey
+	}
+	r.noncePrefix = header[len(Magic)+fingerprintLen:]
+	r.n = 0
+	r.inStream = true
+	return nil
+}
+
+// NewReader returns reader decrypting stream written by Writer
+func NewReader(r io.Reader, key []byte) (*Reader, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{
+		r:    bufio.NewReader(r),
+		aead: aead,
+		key:  key,
+	}, nil
+}
@@ -0,0 +1,61 @@
+package encryption
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+var (
+	key = []byte("0123456789abcdef0123456789abcdef")
+)
+
+func TestWriterReader(t *testing.T) {
+	a := assert.New(t)
+
+	buf := bytes.Buffer{}
+	plain := bytes.Repeat([]byte("journal entry "), 100)
+
+	w, err := NewWriterSize(&buf, key, 64)
+	a.NoError(err)
+	_, err = w.Write(plain[:500])
+	a.NoError(err)
+	a.NoError(w.Flush())
+	_, err = w.Write(plain[500:])
+	a.NoError(err)
+	a.NoError(w.Close())
+	a.True(IsEncrypted(buf.Bytes()))
+	a.False(bytes.Contains(buf.Bytes(), []byte("journal")))
+
+	// appended stream
+	w, err = NewWriter(&buf, key)
+	a.NoError(err)
+	_, err = w.Write([]byte("appended"))
+	a.NoError(err)
+	a.NoError(w.Close())
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), key)
+	a.NoError(err)
+	data, err := ioutil.ReadAll(r)
+	a.NoError(err)
+	a.Equal(append(plain, "appended"...), data)
+
+	r, err = NewReader(bytes.NewReader(buf.Bytes()[:buf.Len()-10]), key)
+	a.NoError(err)
+	_, err = ioutil.ReadAll(r)
+	a.Equal(ErrTruncated, err)
+
+	// corrupted chunk length must not be trusted
+	corrupted := append([]byte(nil), buf.Bytes()...)
+	corrupted[headerLen+3] = 0x7f
+	r, err = NewReader(bytes.NewReader(corrupted), key)
+	a.NoError(err)
+	_, err = ioutil.ReadAll(r)
+	a.Equal(ErrCorrupted, err)
+
+	r, err = NewReader(bytes.NewReader(buf.Bytes()), []byte("fedcba9876543210"))
+	a.NoError(err)
+	_, err = ioutil.ReadAll(r)
+	a.Equal(ErrWrongKey, err)
+}
@@ -0,0 +1,18 @@
+package entry
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+// ChainHash returns hash of marshaled record stored in PrevHash of the next record,
+// HMAC-SHA256 is used if key is not empty, otherwise SHA256
+func ChainHash(key []byte, record []byte) []byte {
+	if len(key) == 0 {
+		sum := sha256.Sum256(record)
+		return sum[:]
+	}
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write(record)
+	return mac.Sum(nil)
+}
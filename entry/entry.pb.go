@@ -21,14 +21,33 @@ var _ = math.Inf
 const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
 type Entry struct {
-	ModuleName           string   `protobuf:"bytes,1,opt,name=moduleName,proto3" json:"moduleName,omitempty"`
-	Host                 string   `protobuf:"bytes,2,opt,name=host,proto3" json:"host,omitempty"`
-	Event                string   `protobuf:"bytes,3,opt,name=event,proto3" json:"event,omitempty"`
-	Level                string   `protobuf:"bytes,4,opt,name=level,proto3" json:"level,omitempty"`
-	Time                 string   `protobuf:"bytes,5,opt,name=time,proto3" json:"time,omitempty"`
-	Request              []byte   `protobuf:"bytes,6,opt,name=request,proto3" json:"request,omitempty"`
-	Response             []byte   `protobuf:"bytes,7,opt,name=response,proto3" json:"response,omitempty"`
-	ErrorText            string   `protobuf:"bytes,8,opt,name=errorText,proto3" json:"errorText,omitempty"`
+	ModuleName string            `protobuf:"bytes,1,opt,name=moduleName,proto3" json:"moduleName,omitempty"`
+	Host       string            `protobuf:"bytes,2,opt,name=host,proto3" json:"host,omitempty"`
+	Event      string            `protobuf:"bytes,3,opt,name=event,proto3" json:"event,omitempty"`
+	Level      string            `protobuf:"bytes,4,opt,name=level,proto3" json:"level,omitempty"`
+	Time       string            `protobuf:"bytes,5,opt,name=time,proto3" json:"time,omitempty"`
+	Request    []byte            `protobuf:"bytes,6,opt,name=request,proto3" json:"request,omitempty"`
+	Response   []byte            `protobuf:"bytes,7,opt,name=response,proto3" json:"response,omitempty"`
+	ErrorText  string            `protobuf:"bytes,8,opt,name=errorText,proto3" json:"errorText,omitempty"`
+	TraceId    string            `protobuf:"bytes,9,opt,name=traceId,proto3" json:"traceId,omitempty"`
+	SpanId     string            `protobuf:"bytes,10,opt,name=spanId,proto3" json:"spanId,omitempty"`
+	RequestId  string            `protobuf:"bytes,11,opt,name=requestId,proto3" json:"requestId,omitempty"`
+	Labels     map[string]string `protobuf:"bytes,12,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// nanoseconds
+	Duration int64 `protobuf:"varint,13,opt,name=duration,proto3" json:"duration,omitempty"`
+	// fraction of entries kept by sampling, 0 means all entries are kept
+	SampleRate float64 `protobuf:"fixed64,14,opt,name=sampleRate,proto3" json:"sampleRate,omitempty"`
+	// request or response was cut to max payload size
+	Truncated bool `protobuf:"varint,15,opt,name=truncated,proto3" json:"truncated,omitempty"`
+	// original payload lengths, filled when entry is truncated
+	RequestLength  int64 `protobuf:"varint,16,opt,name=requestLength,proto3" json:"requestLength,omitempty"`
+	ResponseLength int64 `protobuf:"varint,17,opt,name=responseLength,proto3" json:"responseLength,omitempty"`
+	// per host monotonic sequence number, starts from 1
+	Seq uint64 `protobuf:"varint,18,opt,name=seq,proto3" json:"seq,omitempty"`
+	// unique lexicographically sortable id, see NewId
+	Id string `protobuf:"bytes,19,opt,name=id,proto3" json:"id,omitempty"`
+	// hash of previous marshaled entry when hash chain is enabled, see ChainHash
+	PrevHash             []byte   `protobuf:"bytes,20,opt,name=prevHash,proto3" json:"prevHash,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -115,24 +134,278 @@ func (m *Entry) GetErrorText() string {
 	return ""
 }
 
+func (m *Entry) GetTraceId() string {
+	if m != nil {
+		return m.TraceId
+	}
+	return ""
+}
+
+func (m *Entry) GetSpanId() string {
+	if m != nil {
+		return m.SpanId
+	}
+	return ""
+}
+
+func (m *Entry) GetRequestId() string {
+	if m != nil {
+		return m.RequestId
+	}
+	return ""
+}
+
+func (m *Entry) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+func (m *Entry) GetDuration() int64 {
+	if m != nil {
+		return m.Duration
+	}
+	return 0
+}
+
+func (m *Entry) GetSampleRate() float64 {
+	if m != nil {
+		return m.SampleRate
+	}
+	return 0
+}
+
+func (m *Entry) GetTruncated() bool {
+	if m != nil {
+		return m.Truncated
+	}
+	return false
+}
+
+func (m *Entry) GetRequestLength() int64 {
+	if m != nil {
+		return m.RequestLength
+	}
+	return 0
+}
+
+func (m *Entry) GetResponseLength() int64 {
+	if m != nil {
+		return m.ResponseLength
+	}
+	return 0
+}
+
+func (m *Entry) GetSeq() uint64 {
+	if m != nil {
+		return m.Seq
+	}
+	return 0
+}
+
+func (m *Entry) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Entry) GetPrevHash() []byte {
+	if m != nil {
+		return m.PrevHash
+	}
+	return nil
+}
+
+// FileHeader is written at the beginning of log file before compressed and encrypted data
+type FileHeader struct {
+	Version              uint32   `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	ModuleName           string   `protobuf:"bytes,2,opt,name=moduleName,proto3" json:"moduleName,omitempty"`
+	Host                 string   `protobuf:"bytes,3,opt,name=host,proto3" json:"host,omitempty"`
+	Codec                string   `protobuf:"bytes,4,opt,name=codec,proto3" json:"codec,omitempty"`
+	Encrypted            bool     `protobuf:"varint,5,opt,name=encrypted,proto3" json:"encrypted,omitempty"`
+	CreatedAt            string   `protobuf:"bytes,6,opt,name=createdAt,proto3" json:"createdAt,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *FileHeader) Reset()         { *m = FileHeader{} }
+func (m *FileHeader) String() string { return proto.CompactTextString(m) }
+func (*FileHeader) ProtoMessage()    {}
+func (*FileHeader) Descriptor() ([]byte, []int) {
+	return fileDescriptor_daa6c5b6c627940f, []int{1}
+}
+
+func (m *FileHeader) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_FileHeader.Unmarshal(m, b)
+}
+func (m *FileHeader) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_FileHeader.Marshal(b, m, deterministic)
+}
+func (m *FileHeader) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FileHeader.Merge(m, src)
+}
+func (m *FileHeader) XXX_Size() int {
+	return xxx_messageInfo_FileHeader.Size(m)
+}
+func (m *FileHeader) XXX_DiscardUnknown() {
+	xxx_messageInfo_FileHeader.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FileHeader proto.InternalMessageInfo
+
+func (m *FileHeader) GetVersion() uint32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *FileHeader) GetModuleName() string {
+	if m != nil {
+		return m.ModuleName
+	}
+	return ""
+}
+
+func (m *FileHeader) GetHost() string {
+	if m != nil {
+		return m.Host
+	}
+	return ""
+}
+
+func (m *FileHeader) GetCodec() string {
+	if m != nil {
+		return m.Codec
+	}
+	return ""
+}
+
+func (m *FileHeader) GetEncrypted() bool {
+	if m != nil {
+		return m.Encrypted
+	}
+	return false
+}
+
+func (m *FileHeader) GetCreatedAt() string {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return ""
+}
+
+// FileTrailer is written at the end of log file on rotation
+type FileTrailer struct {
+	Entries int64  `protobuf:"varint,1,opt,name=entries,proto3" json:"entries,omitempty"`
+	MinTime string `protobuf:"bytes,2,opt,name=minTime,proto3" json:"minTime,omitempty"`
+	MaxTime string `protobuf:"bytes,3,opt,name=maxTime,proto3" json:"maxTime,omitempty"`
+	// count of entries by level
+	Levels               map[string]int64 `protobuf:"bytes,4,rep,name=levels,proto3" json:"levels,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
+	XXX_unrecognized     []byte           `json:"-"`
+	XXX_sizecache        int32            `json:"-"`
+}
+
+func (m *FileTrailer) Reset()         { *m = FileTrailer{} }
+func (m *FileTrailer) String() string { return proto.CompactTextString(m) }
+func (*FileTrailer) ProtoMessage()    {}
+func (*FileTrailer) Descriptor() ([]byte, []int) {
+	return fileDescriptor_daa6c5b6c627940f, []int{2}
+}
+
+func (m *FileTrailer) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_FileTrailer.Unmarshal(m, b)
+}
+func (m *FileTrailer) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_FileTrailer.Marshal(b, m, deterministic)
+}
+func (m *FileTrailer) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FileTrailer.Merge(m, src)
+}
+func (m *FileTrailer) XXX_Size() int {
+	return xxx_messageInfo_FileTrailer.Size(m)
+}
+func (m *FileTrailer) XXX_DiscardUnknown() {
+	xxx_messageInfo_FileTrailer.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FileTrailer proto.InternalMessageInfo
+
+func (m *FileTrailer) GetEntries() int64 {
+	if m != nil {
+		return m.Entries
+	}
+	return 0
+}
+
+func (m *FileTrailer) GetMinTime() string {
+	if m != nil {
+		return m.MinTime
+	}
+	return ""
+}
+
+func (m *FileTrailer) GetMaxTime() string {
+	if m != nil {
+		return m.MaxTime
+	}
+	return ""
+}
+
+func (m *FileTrailer) GetLevels() map[string]int64 {
+	if m != nil {
+		return m.Levels
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*Entry)(nil), "entry.Entry")
+	proto.RegisterMapType((map[string]string)(nil), "entry.Entry.LabelsEntry")
+	proto.RegisterType((*FileHeader)(nil), "entry.FileHeader")
+	proto.RegisterType((*FileTrailer)(nil), "entry.FileTrailer")
+	proto.RegisterMapType((map[string]int64)(nil), "entry.FileTrailer.LevelsEntry")
 }
 
 func init() { proto.RegisterFile("entry.proto", fileDescriptor_daa6c5b6c627940f) }
 
 var fileDescriptor_daa6c5b6c627940f = []byte{
-	// 182 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x4c, 0x8f, 0xbd, 0xaa, 0xc2, 0x40,
-	0x10, 0x85, 0xd9, 0x7b, 0xb3, 0xf9, 0x99, 0x7b, 0xab, 0xc1, 0x62, 0x10, 0x91, 0x60, 0x95, 0xca,
-	0xc6, 0x67, 0xb0, 0xb5, 0x08, 0xbe, 0x80, 0xe2, 0x80, 0x42, 0x92, 0x8d, 0xbb, 0x93, 0xa0, 0x4f,
-	0xe9, 0x2b, 0xc9, 0x4e, 0xf0, 0xa7, 0x3b, 0xdf, 0x77, 0x38, 0x03, 0x03, 0x7f, 0xdc, 0x89, 0xbf,
-	0xaf, 0x7b, 0xef, 0xc4, 0xa1, 0x55, 0x58, 0x3d, 0x0c, 0xd8, 0x6d, 0x4c, 0xb8, 0x04, 0x68, 0xdd,
-	0x69, 0x68, 0x78, 0x77, 0x68, 0x99, 0x4c, 0x69, 0xaa, 0xa2, 0xfe, 0x32, 0x88, 0x90, 0x9c, 0x5d,
-	0x10, 0xfa, 0xd1, 0x46, 0x33, 0xce, 0xc0, 0xf2, 0xc8, 0x9d, 0xd0, 0xaf, 0xca, 0x09, 0xa2, 0x6d,
-	0x78, 0xe4, 0x86, 0x92, 0xc9, 0x2a, 0xc4, 0xbd, 0x5c, 0x5a, 0x26, 0x3b, 0xed, 0x63, 0x46, 0x82,
-	0xcc, 0xf3, 0x75, 0xe0, 0x20, 0x94, 0x96, 0xa6, 0xfa, 0xaf, 0x5f, 0x88, 0x73, 0xc8, 0x3d, 0x87,
-	0xde, 0x75, 0x81, 0x29, 0xd3, 0xea, 0xcd, 0xb8, 0x80, 0x82, 0xbd, 0x77, 0x7e, 0xcf, 0x37, 0xa1,
-	0x5c, 0xcf, 0x7d, 0xc4, 0x31, 0xd5, 0xff, 0x36, 0xcf, 0x00, 0x00, 0x00, 0xff, 0xff, 0x28, 0x58,
-	0x75, 0x2f, 0xee, 0x00, 0x00, 0x00,
+	// 521 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x54, 0xd1, 0x8a, 0xd3, 0x40,
+	0x14, 0x65, 0x9a, 0xb6, 0xbb, 0x9d, 0x6e, 0xeb, 0x3a, 0x2e, 0x72, 0x59, 0x64, 0x09, 0x45, 0x24,
+	0x4f, 0x45, 0x14, 0x44, 0x7d, 0xf3, 0x41, 0xd9, 0x42, 0xf1, 0x61, 0xe8, 0x0f, 0xcc, 0x26, 0x17,
+	0x1b, 0x4c, 0x93, 0xec, 0xcc, 0x34, 0x6c, 0xff, 0xc9, 0x6f, 0xf1, 0x63, 0xfc, 0x02, 0xb9, 0x77,
+	0x92, 0x36, 0xbb, 0x20, 0xf8, 0x76, 0xcf, 0x39, 0x77, 0x6e, 0xee, 0x9c, 0x39, 0xad, 0x9c, 0x62,
+	0xe9, 0xed, 0x61, 0x59, 0xdb, 0xca, 0x57, 0x6a, 0xc4, 0x60, 0xf1, 0x67, 0x28, 0x47, 0x5f, 0xa9,
+	0x52, 0x37, 0x52, 0xee, 0xaa, 0x6c, 0x5f, 0xe0, 0x77, 0xb3, 0x43, 0x10, 0xb1, 0x48, 0x26, 0xba,
+	0xc7, 0x28, 0x25, 0x87, 0xdb, 0xca, 0x79, 0x18, 0xb0, 0xc2, 0xb5, 0xba, 0x92, 0x23, 0x6c, 0xb0,
+	0xf4, 0x10, 0x31, 0x19, 0x00, 0xb1, 0x05, 0x36, 0x58, 0xc0, 0x30, 0xb0, 0x0c, 0xe8, 0xbc, 0xcf,
+	0x77, 0x08, 0xa3, 0x70, 0x9e, 0x6a, 0x05, 0xf2, 0xcc, 0xe2, 0xfd, 0x1e, 0x9d, 0x87, 0x71, 0x2c,
+	0x92, 0x0b, 0xdd, 0x41, 0x75, 0x2d, 0xcf, 0x2d, 0xba, 0xba, 0x2a, 0x1d, 0xc2, 0x19, 0x4b, 0x47,
+	0xac, 0x5e, 0xc9, 0x09, 0x5a, 0x5b, 0xd9, 0x0d, 0x3e, 0x78, 0x38, 0xe7, 0x71, 0x27, 0x82, 0x66,
+	0x7a, 0x6b, 0x52, 0x5c, 0x65, 0x30, 0x61, 0xad, 0x83, 0xea, 0xa5, 0x1c, 0xbb, 0xda, 0x94, 0xab,
+	0x0c, 0x24, 0x0b, 0x2d, 0xa2, 0x79, 0xed, 0x67, 0x57, 0x19, 0x4c, 0xc3, 0xbc, 0x23, 0xa1, 0xde,
+	0xca, 0x71, 0x61, 0xee, 0xb0, 0x70, 0x70, 0x11, 0x47, 0xc9, 0xf4, 0x1d, 0x2c, 0x83, 0x8d, 0xec,
+	0xda, 0x72, 0xcd, 0x12, 0xd7, 0xba, 0xed, 0xa3, 0xdd, 0xb3, 0xbd, 0x35, 0x3e, 0xaf, 0x4a, 0x98,
+	0xc5, 0x22, 0x89, 0xf4, 0x11, 0x93, 0xcb, 0xce, 0xec, 0xea, 0x02, 0xb5, 0xf1, 0x08, 0xf3, 0x58,
+	0x24, 0x42, 0xf7, 0x18, 0xda, 0xc5, 0xdb, 0x7d, 0x99, 0x1a, 0x8f, 0x19, 0x3c, 0x8b, 0x45, 0x72,
+	0xae, 0x4f, 0x84, 0x7a, 0x2d, 0x67, 0xed, 0x62, 0x6b, 0x2c, 0x7f, 0xf8, 0x2d, 0x5c, 0xf2, 0xf8,
+	0xc7, 0xa4, 0x7a, 0x23, 0xe7, 0x9d, 0x57, 0x6d, 0xdb, 0x73, 0x6e, 0x7b, 0xc2, 0xaa, 0x4b, 0x19,
+	0x39, 0xbc, 0x07, 0x15, 0x8b, 0x64, 0xa8, 0xa9, 0x54, 0x73, 0x39, 0xc8, 0x33, 0x78, 0xc1, 0x16,
+	0x0c, 0xf2, 0x8c, 0x6e, 0x52, 0x5b, 0x6c, 0x6e, 0x8d, 0xdb, 0xc2, 0x55, 0x78, 0x85, 0x0e, 0x5f,
+	0x7f, 0x92, 0xd3, 0xde, 0xe5, 0x69, 0xd8, 0x4f, 0x3c, 0xb4, 0xb9, 0xa1, 0x92, 0x62, 0xd0, 0x98,
+	0x62, 0x8f, 0x6d, 0x62, 0x02, 0xf8, 0x3c, 0xf8, 0x28, 0x16, 0xbf, 0x84, 0x94, 0xdf, 0xf2, 0x02,
+	0x6f, 0xd1, 0x64, 0x68, 0xe9, 0xc5, 0x1a, 0xb4, 0x8e, 0xec, 0xa2, 0xe3, 0x33, 0xdd, 0xc1, 0x27,
+	0x99, 0x1c, 0xfc, 0x33, 0x93, 0xd1, 0xe3, 0x4c, 0xa6, 0x55, 0x86, 0x69, 0x97, 0x3e, 0x06, 0x9c,
+	0x99, 0x32, 0xb5, 0x87, 0x9a, 0x7c, 0x1d, 0x05, 0x5f, 0x8f, 0x04, 0xa9, 0xa9, 0x45, 0xb2, 0xf8,
+	0x4b, 0x48, 0xe2, 0x44, 0x9f, 0x88, 0xc5, 0x6f, 0x21, 0xa7, 0xb4, 0xee, 0xc6, 0x9a, 0xbc, 0x08,
+	0xfb, 0x52, 0x04, 0x72, 0x74, 0xbc, 0x6f, 0xa4, 0x3b, 0x48, 0xca, 0x2e, 0x2f, 0x37, 0xf9, 0x71,
+	0xd9, 0x0e, 0xb2, 0x62, 0x1e, 0x58, 0x89, 0x5a, 0x25, 0x40, 0xf5, 0x41, 0x8e, 0xf9, 0x07, 0xe2,
+	0x60, 0xc8, 0xf9, 0xba, 0x69, 0xf3, 0xd5, 0xfb, 0xe2, 0x72, 0x8d, 0x4d, 0x67, 0xb4, 0x6e, 0xbb,
+	0xd9, 0x7f, 0x6c, 0xfe, 0xd7, 0xff, 0xa8, 0xe7, 0xff, 0xdd, 0x98, 0xff, 0x02, 0xde, 0xff, 0x1d,
+	0x00, 0xe0, 0xfb, 0x05, 0xc3, 0x11, 0x04, 0x00, 0x00,
 }
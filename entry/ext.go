@@ -6,7 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"github.com/golang/protobuf/proto"
+	"hash/crc32"
 	"io"
+	"strings"
 	"sync"
 	"time"
 )
@@ -14,16 +16,37 @@ import (
 type Level string
 
 const (
-	LevelError = "ERROR"
-	LevelInfo  = "OK"
-	LevelWarn  = "WARN"
+	LevelDebug Level = "DEBUG"
+	LevelInfo  Level = "OK"
+	LevelWarn  Level = "WARN"
+	LevelError Level = "ERROR"
+	LevelFatal Level = "FATAL"
 )
 
 const (
 	timeFormat = "2006-01-02T15:04:05.999-07:00"
+
+	// checksumFlag is set in length prefix of records followed by CRC32C of marshaled entry
+	checksumFlag = 1 << 31
+	checksumLen  = 4
 )
 
 var (
+	levelSeverity = map[Level]int{
+		LevelDebug: 0,
+		LevelInfo:  1,
+		LevelWarn:  2,
+		LevelError: 3,
+		LevelFatal: 4,
+	}
+	levelAliases = map[string]Level{
+		"INFO": LevelInfo,
+	}
+
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+	ErrChecksumMismatch = errors.New("record checksum mismatch")
+
 	pool = sync.Pool{
 		New: func() interface{} {
 			return bytes.NewBuffer(make([]byte, 0, 4096))
@@ -31,6 +54,31 @@ var (
 	}
 )
 
+// Severity returns level order from DEBUG to FATAL, unknown levels are treated as OK
+func (l Level) Severity() int {
+	if severity, ok := levelSeverity[l]; ok {
+		return severity
+	}
+	return levelSeverity[LevelInfo]
+}
+
+// AtLeast reports whether level is not lower than min, empty min matches any level
+func (l Level) AtLeast(min Level) bool {
+	return min == "" || l.Severity() >= min.Severity()
+}
+
+// ParseLevel parses level name ignoring case, INFO is accepted as alias of OK
+func ParseLevel(s string) (Level, error) {
+	name := strings.ToUpper(strings.TrimSpace(s))
+	if level, ok := levelAliases[name]; ok {
+		return level, nil
+	}
+	if _, ok := levelSeverity[Level(name)]; ok {
+		return Level(name), nil
+	}
+	return "", fmt.Errorf("unknown level '%s'", s)
+}
+
 func FormatTime(time time.Time) string {
 	return time.Format(timeFormat)
 }
@@ -39,14 +87,50 @@ func ParserTime(s string) (time.Time, error) {
 	return time.Parse(timeFormat, s)
 }
 
+// Weight returns count of entries which current entry represents after sampling
+func (m *Entry) Weight() float64 {
+	if m == nil || m.SampleRate <= 0 {
+		return 1
+	}
+	return 1 / m.SampleRate
+}
+
 func MarshalToBytes(entry *Entry) ([]byte, error) {
 	bytes, err := proto.Marshal(entry)
 	if err != nil {
 		return nil, err
 	}
-	l := make([]byte, 4)
-	binary.LittleEndian.PutUint32(l, uint32(len(bytes)))
-	return append(l, bytes...), nil
+	return FrameRecord(bytes, false), nil
+}
+
+// MarshalToBytesWithChecksum appends CRC32C of marshaled entry, such records are read by the same functions
+func MarshalToBytesWithChecksum(entry *Entry) ([]byte, error) {
+	bytes, err := proto.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	return FrameRecord(bytes, true), nil
+}
+
+// FrameRecord adds length prefix and optional checksum to marshaled entry
+func FrameRecord(data []byte, checksum bool) []byte {
+	l := uint32(len(data))
+	if checksum {
+		l |= checksumFlag
+	}
+	record := make([]byte, 4, 4+len(data)+checksumLen)
+	binary.LittleEndian.PutUint32(record, l)
+	record = append(record, data...)
+	if checksum {
+		record = appendUint32(record, crc32.Checksum(data, crcTable))
+	}
+	return record
+}
+
+// ParseLengthPrefix returns length of marshaled entry and whether it is followed by checksum
+func ParseLengthPrefix(prefix []byte) (int, bool) {
+	l := binary.LittleEndian.Uint32(prefix)
+	return int(l &^ checksumFlag), l&checksumFlag != 0
 }
 
 func UnmarshalNext(r io.Reader) (*Entry, error) {
@@ -56,28 +140,59 @@ func UnmarshalNext(r io.Reader) (*Entry, error) {
 		pool.Put(buf)
 	}()
 
+	if err := readNext(r, buf); err != nil {
+		return nil, err
+	}
+
+	e := &Entry{}
+	if err := proto.Unmarshal(buf.Bytes(), e); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// ReadNext returns marshaled entry without length prefix
+func ReadNext(r io.Reader) ([]byte, error) {
+	buf := bytes.Buffer{}
+	if err := readNext(r, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func readNext(r io.Reader, buf *bytes.Buffer) error {
 	n, err := io.CopyN(buf, r, 4)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	if n != 4 {
-		return nil, errors.New("expecting int32 data length prefix")
+		return errors.New("expecting int32 data length prefix")
 	}
-	l := int64(binary.LittleEndian.Uint32(buf.Bytes()))
+	length, checksum := ParseLengthPrefix(buf.Bytes())
+	l := int64(length)
 
 	buf.Reset()
 	n, err = io.CopyN(buf, r, l)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	if n != l {
-		return nil, fmt.Errorf("not enough %d bytes", l-n)
+		return fmt.Errorf("not enough %d bytes", l-n)
 	}
-
-	e := &Entry{}
-	if err := proto.Unmarshal(buf.Bytes(), e); err != nil {
-		return nil, err
+	if !checksum {
+		return nil
 	}
 
-	return e, nil
+	crc := make([]byte, checksumLen)
+	if _, err := io.ReadFull(r, crc); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if binary.LittleEndian.Uint32(crc) != crc32.Checksum(buf.Bytes(), crcTable) {
+		return ErrChecksumMismatch
+	}
+	return nil
 }
@@ -0,0 +1,284 @@
+package entry
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"github.com/integration-system/isp-journal/codec"
+	"github.com/integration-system/isp-journal/encryption"
+	"google.golang.org/protobuf/encoding/protowire"
+	"io"
+)
+
+// Log file layout, header and trailer are optional and never compressed or encrypted:
+//   header:  file magic | version (1 byte) | length (4 bytes LE) | FileHeader
+//   body:    encrypted and/or compressed stream of length prefixed entries
+//   trailer: FileTrailer | length (4 bytes LE) | trailer magic | version (1 byte)
+
+const (
+	FileFormatVersion = 1
+
+	magicLen = 7
+	// maxTrailerSize limits trailer, so readers know how many tail bytes may belong to it
+	maxTrailerSize = 64 * 1024
+	frameLen       = magicLen + 1 + 4
+	readBufSize    = 64 * 1024
+)
+
+var (
+	fileMagic    = []byte("ISPJLOG")
+	trailerMagic = []byte("ISPJEND")
+
+	ErrUnsupportedVersion = errors.New("unsupported log file format version")
+)
+
+func WriteFileHeader(w io.Writer, h *FileHeader) error {
+	if h.Version == 0 {
+		h.Version = FileFormatVersion
+	}
+	data, err := proto.Marshal(h)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 0, frameLen+len(data))
+	buf = append(buf, fileMagic...)
+	buf = append(buf, byte(h.Version))
+	buf = appendUint32(buf, uint32(len(data)))
+	buf = append(buf, data...)
+	_, err = w.Write(buf)
+	return err
+}
+
+// ReadFileHeader returns nil if file has no header, r is read only if header is present
+func ReadFileHeader(r *bufio.Reader) (*FileHeader, error) {
+	head, _ := r.Peek(frameLen)
+	if len(head) < frameLen || !bytes.HasPrefix(head, fileMagic) {
+		return nil, nil
+	}
+	if head[magicLen] > FileFormatVersion {
+		return nil, ErrUnsupportedVersion
+	}
+	l := binary.LittleEndian.Uint32(head[magicLen+1:])
+	data := make([]byte, frameLen+int(l))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("invalid log file header: %v", err)
+	}
+	h := &FileHeader{}
+	if err := proto.Unmarshal(data[frameLen:], h); err != nil {
+		return nil, fmt.Errorf("invalid log file header: %v", err)
+	}
+	return h, nil
+}
+
+func WriteFileTrailer(w io.Writer, t *FileTrailer) error {
+	data, err := proto.Marshal(t)
+	if err != nil {
+		return err
+	}
+	if len(data) > maxTrailerSize {
+		return errors.New("log file trailer is too big")
+	}
+	data = appendUint32(data, uint32(len(data)))
+	data = append(data, trailerMagic...)
+	data = append(data, FileFormatVersion)
+	_, err = w.Write(data)
+	return err
+}
+
+// ReadFileTrailer returns nil if file has no trailer
+func ReadFileTrailer(r io.ReaderAt, size int64) (*FileTrailer, error) {
+	if size < frameLen {
+		return nil, nil
+	}
+	frame := make([]byte, frameLen)
+	if _, err := r.ReadAt(frame, size-frameLen); err != nil {
+		return nil, err
+	}
+	l, ok := trailerLen(frame, size)
+	if !ok {
+		return nil, nil
+	}
+	data := make([]byte, l)
+	if _, err := r.ReadAt(data, size-frameLen-l); err != nil {
+		return nil, err
+	}
+	t := &FileTrailer{}
+	if err := proto.Unmarshal(data, t); err != nil {
+		return nil, fmt.Errorf("invalid log file trailer: %v", err)
+	}
+	return t, nil
+}
+
+// ReadFileSummary reads header and trailer without reading entries, trailer is nil if file has no header
+func ReadFileSummary(r io.ReaderAt, size int64) (*FileHeader, *FileTrailer, error) {
+	header, err := ReadFileHeader(bufio.NewReaderSize(io.NewSectionReader(r, 0, size), frameLen))
+	if err != nil || header == nil {
+		return nil, nil, err
+	}
+	trailer, err := ReadFileTrailer(r, size)
+	if err != nil {
+		return nil, nil, err
+	}
+	return header, trailer, nil
+}
+
+// trailerLen checks frame at the end of data of specified size
+func trailerLen(frame []byte, size int64) (int64, bool) {
+	if !bytes.Equal(frame[4:4+magicLen], trailerMagic) || frame[frameLen-1] > FileFormatVersion {
+		return 0, false
+	}
+	l := int64(binary.LittleEndian.Uint32(frame))
+	if l > maxTrailerSize || l+frameLen > size {
+		return 0, false
+	}
+	return l, true
+}
+
+// ScanLevelAndTime reads level and time of marshaled entry without unmarshaling payloads
+func ScanLevelAndTime(record []byte) (level string, time string, err error) {
+	for len(record) > 0 {
+		num, typ, n := protowire.ConsumeTag(record)
+		if n < 0 {
+			return "", "", protowire.ParseError(n)
+		}
+		record = record[n:]
+		if typ == protowire.BytesType && (num == 4 || num == 5) {
+			value, n := protowire.ConsumeBytes(record)
+			if n < 0 {
+				return "", "", protowire.ParseError(n)
+			}
+			if num == 4 {
+				level = string(value)
+			} else {
+				time = string(value)
+			}
+			record = record[n:]
+			continue
+		}
+		n = protowire.ConsumeFieldValue(num, typ, record)
+		if n < 0 {
+			return "", "", protowire.ParseError(n)
+		}
+		record = record[n:]
+	}
+	return level, time, nil
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	l := make([]byte, 4)
+	binary.LittleEndian.PutUint32(l, v)
+	return append(b, l...)
+}
+
+// FileReader reads length prefixed entries from log file. Header is parsed, trailer is skipped,
+// encryption and compression are detected by magic bytes.
+type FileReader struct {
+	header       *FileHeader
+	decompressed io.ReadCloser
+}
+
+func (f *FileReader) Read(p []byte) (int, error) {
+	return f.decompressed.Read(p)
+}
+
+// Header returns nil if file has no header
+func (f *FileReader) Header() *FileHeader {
+	return f.header
+}
+
+// Close closes decompressor, underlying reader is not closed
+func (f *FileReader) Close() error {
+	return f.decompressed.Close()
+}
+
+// NewFileReader returns reader of log file, keyProvider is required for encrypted files only.
+// Codec is taken from file header or else from knownCodec, only if both are unknown
+// it is detected by magic bytes and defaultCodec is used if compression is not detected
+func NewFileReader(r io.Reader, keyProvider func() ([]byte, error), knownCodec, defaultCodec codec.Codec) (*FileReader, error) {
+	buf := bufio.NewReaderSize(r, readBufSize)
+	header, err := ReadFileHeader(buf)
+	if err != nil {
+		return nil, err
+	}
+	// trailer is written only to files with header
+	if header != nil {
+		buf = bufio.NewReaderSize(&trailerStripper{r: buf}, readBufSize)
+	}
+
+	if head, _ := buf.Peek(len(encryption.Magic)); encryption.IsEncrypted(head) {
+		if keyProvider == nil {
+			return nil, encryption.ErrNoKey
+		}
+		key, err := keyProvider()
+		if err != nil {
+			return nil, err
+		}
+		decrypted, err := encryption.NewReader(buf, key)
+		if err != nil {
+			return nil, err
+		}
+		buf = bufio.NewReaderSize(decrypted, readBufSize)
+	}
+
+	c := knownCodec
+	if header != nil && header.Codec != "" {
+		c = codec.Codec(header.Codec)
+	}
+	if c == codec.Unknown {
+		head, _ := buf.Peek(codec.MagicLen)
+		if c = codec.Detect(head); c == codec.None {
+			c = defaultCodec
+		}
+	}
+	decompressed, err := codec.NewReader(c, buf)
+	if err != nil {
+		return nil, err
+	}
+	return &FileReader{header: header, decompressed: decompressed}, nil
+}
+
+// trailerStripper holds back the tail of stream which may be trailer and drops trailer at the end
+type trailerStripper struct {
+	r   io.Reader
+	buf []byte
+	eof bool
+}
+
+func (t *trailerStripper) Read(p []byte) (int, error) {
+	const hold = maxTrailerSize + frameLen
+	for !t.eof && len(t.buf) <= hold {
+		chunk := make([]byte, readBufSize)
+		n, err := t.r.Read(chunk)
+		t.buf = append(t.buf, chunk[:n]...)
+		if err == io.EOF {
+			t.eof = true
+			t.stripTrailer()
+		} else if err != nil {
+			return 0, err
+		}
+	}
+
+	available := t.buf
+	if !t.eof {
+		available = t.buf[:len(t.buf)-hold]
+	}
+	if len(available) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, available)
+	t.buf = t.buf[n:]
+	return n, nil
+}
+
+func (t *trailerStripper) stripTrailer() {
+	size := int64(len(t.buf))
+	if size < frameLen {
+		return
+	}
+	if l, ok := trailerLen(t.buf[size-frameLen:], size); ok {
+		t.buf = t.buf[:size-frameLen-l]
+	}
+}
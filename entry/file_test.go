@@ -0,0 +1,68 @@
+package entry
+
+import (
+	"bytes"
+	"github.com/integration-system/isp-journal/codec"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func TestFileHeaderAndTrailer(t *testing.T) {
+	a := assert.New(t)
+
+	record, err := MarshalToBytes(&Entry{Level: string(LevelWarn), Time: "2020-11-01T10:00:00.5+00:00", Request: []byte("req")})
+	a.NoError(err)
+
+	data := bytes.Buffer{}
+	a.NoError(WriteFileHeader(&data, &FileHeader{ModuleName: "module", Host: "host", Codec: string(codec.Gzip)}))
+	w, err := codec.NewWriter(codec.Gzip, &data, 0)
+	a.NoError(err)
+	_, err = w.Write(record)
+	a.NoError(err)
+	a.NoError(w.Close())
+	a.NoError(WriteFileTrailer(&data, &FileTrailer{Entries: 1, Levels: map[string]int64{string(LevelWarn): 1}}))
+
+	header, trailer, err := ReadFileSummary(bytes.NewReader(data.Bytes()), int64(data.Len()))
+	a.NoError(err)
+	a.EqualValues(FileFormatVersion, header.Version)
+	a.Equal("module", header.ModuleName)
+	a.EqualValues(1, trailer.Entries)
+	a.EqualValues(1, trailer.Levels[string(LevelWarn)])
+
+	// codec of header has priority
+	r, err := NewFileReader(bytes.NewReader(data.Bytes()), nil, codec.None, codec.None)
+	a.NoError(err)
+	a.Equal("host", r.Header().Host)
+	decoded, err := ioutil.ReadAll(r)
+	a.NoError(err)
+	a.Equal(record, decoded)
+
+	level, time, err := ScanLevelAndTime(record[4:])
+	a.NoError(err)
+	a.Equal(string(LevelWarn), level)
+	a.Equal("2020-11-01T10:00:00.5+00:00", time)
+
+	// plain stream without header is read as is
+	r, err = NewFileReader(bytes.NewReader(record), nil, codec.Unknown, codec.None)
+	a.NoError(err)
+	a.Nil(r.Header())
+	decoded, err = ioutil.ReadAll(r)
+	a.NoError(err)
+	a.Equal(record, decoded)
+	header, trailer, err = ReadFileSummary(bytes.NewReader(record), int64(len(record)))
+	a.NoError(err)
+	a.Nil(header)
+	a.Nil(trailer)
+
+	// length prefix of plain record looks like gzip magic
+	record = FrameRecord(make([]byte, 0x8b1f), false)
+	a.Equal([]byte{0x1f, 0x8b}, record[:2])
+	_, err = NewFileReader(bytes.NewReader(record), nil, codec.Unknown, codec.None)
+	a.Error(err)
+	r, err = NewFileReader(bytes.NewReader(record), nil, codec.None, codec.None)
+	a.NoError(err)
+	decoded, err = ioutil.ReadAll(r)
+	a.NoError(err)
+	a.Equal(record, decoded)
+}
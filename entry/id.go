@@ -0,0 +1,97 @@
+package entry
+
+import (
+	"crypto/rand"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// idAlphabet is Crockford's base32
+	idAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	idLen      = 26
+)
+
+var (
+	ErrInvalidId = errors.New("invalid id")
+
+	ids = idGenerator{}
+)
+
+// idGenerator makes 128-bit ids like ULID: 48 bits of unix milliseconds followed by 80 random bits,
+// random part is incremented for ids made within the same millisecond, so ids are monotonic
+type idGenerator struct {
+	lock   sync.Mutex
+	lastMs uint64
+	last   [10]byte
+}
+
+func (g *idGenerator) next(t time.Time) [16]byte {
+	ms := uint64(t.UnixNano() / int64(time.Millisecond))
+
+	g.lock.Lock()
+	if ms <= g.lastMs {
+		ms = g.lastMs
+		for i := len(g.last) - 1; i >= 0; i-- {
+			g.last[i]++
+			if g.last[i] != 0 {
+				break
+			}
+		}
+	} else {
+		g.lastMs = ms
+		_, _ = rand.Read(g.last[:])
+	}
+	var id [16]byte
+	copy(id[6:], g.last[:])
+	g.lock.Unlock()
+
+	for i := 5; i >= 0; i-- {
+		id[i] = byte(ms)
+		ms >>= 8
+	}
+	return id
+}
+
+// NewId returns unique id which is sorted lexicographically by time t
+func NewId(t time.Time) string {
+	id := ids.next(t)
+
+	// 26 symbols hold 130 bits, so id is prefixed with two zero bits
+	b := strings.Builder{}
+	b.Grow(idLen)
+	for i := 0; i < idLen; i++ {
+		v := 0
+		for bit := i*5 - 2; bit < i*5+3; bit++ {
+			v <<= 1
+			if bit >= 0 && id[bit/8]&(0x80>>uint(bit%8)) != 0 {
+				v |= 1
+			}
+		}
+		b.WriteByte(idAlphabet[v])
+	}
+	return b.String()
+}
+
+// IdTime returns time with millisecond precision encoded in id
+func IdTime(id string) (time.Time, error) {
+	if len(id) != idLen {
+		return time.Time{}, ErrInvalidId
+	}
+	// the first 10 symbols hold 50 bits, timestamp is the lower 48 of them
+	ms := uint64(0)
+	prefix := strings.ToUpper(id[:10])
+	for i := 0; i < len(prefix); i++ {
+		v := strings.IndexByte(idAlphabet, prefix[i])
+		if v < 0 {
+			return time.Time{}, ErrInvalidId
+		}
+		ms = ms<<5 | uint64(v)
+	}
+	if ms>>48 != 0 {
+		return time.Time{}, ErrInvalidId
+	}
+	return time.Unix(0, int64(ms)*int64(time.Millisecond)), nil
+}
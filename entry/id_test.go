@@ -0,0 +1,32 @@
+package entry
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNewId(t *testing.T) {
+	a := assert.New(t)
+
+	now := time.Now()
+	prev := ""
+	for i := 0; i < 1000; i++ {
+		id := NewId(now)
+		a.Len(id, idLen)
+		a.True(id > prev, "ids must be monotonic")
+		prev = id
+	}
+
+	later := NewId(now.Add(time.Second))
+	a.True(later > prev)
+
+	idTime, err := IdTime(later)
+	a.NoError(err)
+	a.Equal(now.Add(time.Second).UnixNano()/int64(time.Millisecond), idTime.UnixNano()/int64(time.Millisecond))
+
+	_, err = IdTime("short")
+	a.Equal(ErrInvalidId, err)
+	_, err = IdTime("ZZZZZZZZZZZZZZZZZZZZZZZZZZ")
+	a.Equal(ErrInvalidId, err)
+}
@@ -0,0 +1,139 @@
+package entry
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	SkipInvalidRecord    = "invalid record"
+	SkipChecksumMismatch = "checksum mismatch"
+	SkipTruncatedRecord  = "truncated record"
+	SkipUnreadableStream = "unreadable stream"
+
+	// DefaultMaxRecordSize limits length of record accepted while looking for the next valid record
+	DefaultMaxRecordSize = 64 * 1024 * 1024
+)
+
+// SkippedRange describes damaged data in uncompressed stream of records.
+// To is -1 if the rest of stream could not be decoded.
+type SkippedRange struct {
+	From   int64
+	To     int64
+	Reason string
+}
+
+// RecordScanner reads records like ReadNext, but skips damaged data up to the next valid record.
+// Records without checksum are considered valid if entry level and time can be parsed.
+type RecordScanner struct {
+	r             io.Reader
+	onSkip        func(SkippedRange)
+	maxRecordSize int
+
+	buf    []byte
+	offset int64
+	eof    bool
+	err    error
+}
+
+// Next returns marshaled entry without length prefix and checksum, io.EOF is returned at the end of stream
+func (s *RecordScanner) Next() ([]byte, error) {
+	for {
+		if !s.fill(1) {
+			if s.err != nil {
+				s.skip(SkippedRange{From: s.offset, To: -1, Reason: SkipUnreadableStream + ": " + s.err.Error()})
+				s.err = nil
+			}
+			return nil, io.EOF
+		}
+
+		record, n, reason := s.parseAt(0)
+		if reason == "" {
+			// record is copied, since buffer is reused
+			record = append([]byte(nil), record...)
+			s.consume(n)
+			return record, nil
+		}
+
+		from := s.offset
+		i := 1
+		for ; s.fill(i + 1); i++ {
+			if _, _, r := s.parseAt(i); r == "" {
+				break
+			}
+		}
+		s.consume(i)
+		s.skip(SkippedRange{From: from, To: s.offset, Reason: reason})
+	}
+}
+
+// parseAt returns record at position i of buffer and its full length or reason why record is invalid
+func (s *RecordScanner) parseAt(i int) ([]byte, int, string) {
+	if !s.fill(i + 4) {
+		return nil, 0, SkipTruncatedRecord
+	}
+	l, checksum := ParseLengthPrefix(s.buf[i:])
+	if l > s.maxRecordSize {
+		return nil, 0, SkipInvalidRecord
+	}
+	n := 4 + l
+	if checksum {
+		n += checksumLen
+	}
+	if !s.fill(i + n) {
+		return nil, 0, SkipTruncatedRecord
+	}
+
+	record := s.buf[i+4 : i+4+l]
+	if checksum {
+		if binary.LittleEndian.Uint32(s.buf[i+4+l:]) != crc32.Checksum(record, crcTable) {
+			return nil, 0, SkipChecksumMismatch
+		}
+		return record, n, ""
+	}
+	level, time, err := ScanLevelAndTime(record)
+	if err != nil || level == "" {
+		return nil, 0, SkipInvalidRecord
+	}
+	if _, err := ParserTime(time); err != nil {
+		return nil, 0, SkipInvalidRecord
+	}
+	return record, n, ""
+}
+
+// fill reads stream until buffer contains at least n bytes
+func (s *RecordScanner) fill(n int) bool {
+	for len(s.buf) < n && !s.eof {
+		chunk := make([]byte, readBufSize)
+		read, err := s.r.Read(chunk)
+		s.buf = append(s.buf, chunk[:read]...)
+		if err == io.EOF {
+			s.eof = true
+		} else if err != nil {
+			s.eof = true
+			s.err = err
+		}
+	}
+	return len(s.buf) >= n
+}
+
+func (s *RecordScanner) consume(n int) {
+	s.buf = s.buf[n:]
+	s.offset += int64(n)
+}
+
+func (s *RecordScanner) skip(r SkippedRange) {
+	if s.onSkip != nil {
+		s.onSkip(r)
+	}
+}
+
+// NewRecordScanner returns scanner of uncompressed stream of records, onSkip is called for every skipped range
+func NewRecordScanner(r io.Reader, onSkip func(SkippedRange)) *RecordScanner {
+	return &RecordScanner{
+		r:             r,
+		onSkip:        onSkip,
+		maxRecordSize: DefaultMaxRecordSize,
+	}
+}
@@ -0,0 +1,73 @@
+package entry
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	a := assert.New(t)
+
+	record, err := MarshalToBytesWithChecksum(e)
+	a.NoError(err)
+	l, checksum := ParseLengthPrefix(record)
+	a.True(checksum)
+	a.Equal(len(record)-8, l)
+
+	read, err := UnmarshalNext(bytes.NewReader(record))
+	a.NoError(err)
+	a.Equal(e.Event, read.Event)
+
+	record[10] ^= 0xff
+	_, err = UnmarshalNext(bytes.NewReader(record))
+	a.Equal(ErrChecksumMismatch, err)
+}
+
+func TestRecordScanner(t *testing.T) {
+	a := assert.New(t)
+
+	records := make([][]byte, 0)
+	data := bytes.Buffer{}
+	for i, marshal := range []func(*Entry) ([]byte, error){MarshalToBytes, MarshalToBytesWithChecksum, MarshalToBytes, MarshalToBytesWithChecksum} {
+		copy := *e
+		copy.Seq = uint64(i)
+		record, err := marshal(&copy)
+		a.NoError(err)
+		records = append(records, record)
+		data.Write(record)
+	}
+	stream := data.Bytes()
+	// damage checksum protected record and add half-written tail
+	corruptedAt := len(records[0]) + 10
+	stream[corruptedAt] ^= 0xff
+	stream = append(stream, records[0][:len(records[0])/2]...)
+
+	skipped := make([]SkippedRange, 0)
+	s := NewRecordScanner(bytes.NewReader(stream), func(r SkippedRange) {
+		skipped = append(skipped, r)
+	})
+	seqs := make([]uint64, 0)
+	for {
+		record, err := s.Next()
+		if err == io.EOF {
+			break
+		}
+		a.NoError(err)
+		read, err := UnmarshalNext(bytes.NewReader(append(prefix(len(record)), record...)))
+		a.NoError(err)
+		seqs = append(seqs, read.Seq)
+	}
+
+	a.Equal([]uint64{0, 2, 3}, seqs)
+	end := int64(len(records[0]) + len(records[1]) + len(records[2]) + len(records[3]))
+	a.Equal([]SkippedRange{
+		{From: int64(len(records[0])), To: int64(len(records[0]) + len(records[1])), Reason: SkipChecksumMismatch},
+		{From: end, To: int64(len(stream)), Reason: SkipTruncatedRecord},
+	}, skipped)
+}
+
+func prefix(l int) []byte {
+	return []byte{byte(l), byte(l >> 8), byte(l >> 16), byte(l >> 24)}
+}
@@ -9,6 +9,9 @@ require (
 	github.com/integration-system/isp-lib/v2 v2.7.0
 	github.com/integration-system/isp-log v1.1.6
 	github.com/json-iterator/go v1.1.10
+	github.com/klauspost/compress v1.10.7
+	github.com/pierrec/lz4 v2.6.1+incompatible
 	github.com/stretchr/testify v1.6.1
 	google.golang.org/grpc v1.33.2
+	google.golang.org/protobuf v1.25.0
 )
@@ -1,10 +1,25 @@
 package journal
 
 import (
+	"context"
+	"errors"
+	"github.com/integration-system/isp-journal/codes"
 	"github.com/integration-system/isp-journal/entry"
 	"github.com/integration-system/isp-journal/log"
+	"github.com/integration-system/isp-journal/redact"
+	logger "github.com/integration-system/isp-log"
 	"io"
-	"time"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// payloadReserve is space left in log file for entry fields except request and response
+	payloadReserve = 64 * 1024
+)
+
+var (
+	ErrJournalClosed = errors.New("journal closed")
 )
 
 type Journal interface {
@@ -13,39 +28,173 @@ type Journal interface {
 	Info(event string, req []byte, res []byte) error
 	Warn(event string, req []byte, res []byte, err error) error
 	Error(event string, req []byte, res []byte, err error) error
+	LogCtx(ctx context.Context, status entry.Level, event string, req []byte, res []byte, err error) error
+	InfoCtx(ctx context.Context, event string, req []byte, res []byte) error
+	WarnCtx(ctx context.Context, event string, req []byte, res []byte, err error) error
+	ErrorCtx(ctx context.Context, event string, req []byte, res []byte, err error) error
 	Rotate() error
 }
 
 type fileJournal struct {
-	log log.Logger
+	// seq, degradeDropped and pendingTransfer are accessed atomically
+	seq             uint64
+	degradeDropped  int64
+	pendingTransfer int64
+
+	log     log.Logger
+	config  log.Config
+	counter *entryCounter
 
 	host                 string
 	moduleName           string
+	minLevel             entry.Level
+	sampler              *sampler
+	chain                *hashChain
+	redactor             *redact.Redactor
+	maxPayloadSize       int
 	afterRotation        func(log log.LogFile)
+	afterRemoval         func(log log.LogFile)
+	diskSpaceEvents      func(event log.DiskSpaceEvent)
+	guard                *log.DiskGuard
 	existedLogsCollector func(logs []log.LogFile)
+	// stateLock orders state saves, so older state never replaces newer one
+	stateLock sync.Mutex
+}
+
+// hashChain keeps hash of the last written entry
+type hashChain struct {
+	key      []byte
+	lock     sync.Mutex
+	prevHash []byte
 }
 
 func (j *fileJournal) Log(level entry.Level, event string, req []byte, res []byte, err error) error {
+	return j.LogCtx(context.Background(), level, event, req, res, err)
+}
+
+func (j *fileJournal) LogCtx(ctx context.Context, level entry.Level, event string, req []byte, res []byte, err error) error {
+	if !level.AtLeast(j.minLevel) {
+		return nil
+	}
+	if j.degradeDrops(level) {
+		return nil
+	}
+
+	sampleRate := float64(1)
+	if j.sampler != nil {
+		keep, rate := j.sampler.sample(level, event)
+		if !keep {
+			return nil
+		}
+		sampleRate = rate
+	}
+
+	request, requestTruncated := j.truncate(j.redactor.Redact(req))
+	response, responseTruncated := j.truncate(j.redactor.Redact(res))
+	// payloads are dropped in degraded mode, entry is marked as truncated with original lengths
+	payloadDropped := j.guard.Mode() == log.DegradeDropPayload && (len(req) > 0 || len(res) > 0)
+	if payloadDropped {
+		request, response = nil, nil
+	}
+
+	e := newEntry(ctx, j.moduleName, j.host, level, event, request, response, nil)
+	if sampleRate < 1 {
+		e.SampleRate = sampleRate
+	}
+	if requestTruncated || responseTruncated || payloadDropped {
+		reqLen, resLen := payloadLengths(ctx, req, res)
+		e.Truncated = true
+		e.RequestLength = int64(reqLen)
+		e.ResponseLength = int64(resLen)
+	}
+	if err != nil {
+		e.ErrorText = j.redactor.RedactText(err.Error())
+	}
+
+	if j.chain != nil {
+		return j.writeChained(e, level)
+	}
+	e.Seq = atomic.AddUint64(&j.seq, 1)
+	return j.write(e, level)
+}
+
+// writeChained appends entries to log in the same order as in the chain,
+// but waits for batch commit outside of chain lock.
+// Chain is continued even if write fails, so lost entries are reported by chain verifier
+func (j *fileJournal) writeChained(e *entry.Entry, level entry.Level) error {
+	j.chain.lock.Lock()
+	e.Seq = atomic.AddUint64(&j.seq, 1)
+	e.PrevHash = j.chain.prevHash
+	bytes, err := j.marshal(e)
+	if err != nil {
+		j.chain.lock.Unlock()
+		return err
+	}
+	wait := j.log.Append(bytes)
+	l, _ := entry.ParseLengthPrefix(bytes)
+	j.chain.prevHash = entry.ChainHash(j.chain.key, bytes[4:4+l])
+	j.chain.lock.Unlock()
+
+	if err := wait(); err != nil {
+		return err
+	}
+	j.counter.inc(level)
+	return nil
+}
+
+func (j *fileJournal) write(e *entry.Entry, level entry.Level) error {
+	bytes, err := j.marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err = j.log.Write(bytes); err != nil {
+		return err
+	}
+	j.counter.inc(level)
+	return nil
+}
+
+// marshal returns entry marshaled with length prefix
+func (j *fileJournal) marshal(e *entry.Entry) ([]byte, error) {
+	if j.config.RecordChecksum {
+		return entry.MarshalToBytesWithChecksum(e)
+	}
+	return entry.MarshalToBytes(e)
+}
+
+func newEntry(ctx context.Context, moduleName, host string, level entry.Level, event string, req []byte, res []byte, err error) *entry.Entry {
+	t := entryTime(ctx)
 	e := &entry.Entry{
-		ModuleName: j.moduleName,
-		Host:       j.host,
+		ModuleName: moduleName,
+		Host:       host,
 		Event:      event,
-		Time:       entry.FormatTime(time.Now().UTC()),
+		Time:       entry.FormatTime(t.UTC()),
 		Level:      string(level),
 		Request:    req,
 		Response:   res,
+		TraceId:    TraceIdFromContext(ctx),
+		SpanId:     SpanIdFromContext(ctx),
+		RequestId:  RequestIdFromContext(ctx),
+		Labels:     LabelsFromContext(ctx),
+		Duration:   int64(DurationFromContext(ctx)),
+		Id:         entry.NewId(t),
+	}
+	if reqLen, resLen := payloadLengths(ctx, req, res); reqLen > len(req) || resLen > len(res) {
+		e.Truncated = true
+		e.RequestLength = int64(reqLen)
+		e.ResponseLength = int64(resLen)
 	}
 	if err != nil {
 		e.ErrorText = err.Error()
 	}
+	return e
+}
 
-	bytes, err := entry.MarshalToBytes(e)
-	if err != nil {
-		return err
+func (j *fileJournal) truncate(payload []byte) ([]byte, bool) {
+	if j.maxPayloadSize > 0 && len(payload) > j.maxPayloadSize {
+		return payload[:j.maxPayloadSize], true
 	}
-
-	_, err = j.log.Write(bytes)
-	return err
+	return payload, false
 }
 
 func (j *fileJournal) Info(event string, req []byte, res []byte) error {
@@ -60,25 +209,118 @@ func (j *fileJournal) Error(event string, req []byte, res []byte, err error) err
 	return j.Log(entry.LevelError, event, req, res, err)
 }
 
+func (j *fileJournal) InfoCtx(ctx context.Context, event string, req []byte, res []byte) error {
+	return j.LogCtx(ctx, entry.LevelInfo, event, req, res, nil)
+}
+
+func (j *fileJournal) WarnCtx(ctx context.Context, event string, req []byte, res []byte, err error) error {
+	return j.LogCtx(ctx, entry.LevelWarn, event, req, res, err)
+}
+
+func (j *fileJournal) ErrorCtx(ctx context.Context, event string, req []byte, res []byte, err error) error {
+	return j.LogCtx(ctx, entry.LevelError, event, req, res, err)
+}
+
 func (j *fileJournal) Rotate() error {
 	return j.log.Rotate()
 }
 
 func (j *fileJournal) Close() error {
-	return j.log.Close()
+	j.guard.Close()
+	err := j.log.Close()
+	j.saveState()
+	return err
+}
+
+// onRotation saves state before rotated file is passed to callback, which may transfer and remove it
+func (j *fileJournal) onRotation(prevFile log.LogFile) {
+	atomic.AddInt64(&j.pendingTransfer, 1)
+	j.saveState()
+	if j.afterRotation != nil {
+		j.afterRotation(prevFile)
+		// callback may transfer and remove rotated files
+		j.countPendingTransfer()
+	}
+}
+
+func (j *fileJournal) onRemoval(removed log.LogFile) {
+	atomic.AddInt64(&j.pendingTransfer, -1)
+	if j.afterRemoval != nil {
+		j.afterRemoval(removed)
+	}
+}
+
+// countPendingTransfer scans log directory, so it is called on start and rotations only
+func (j *fileJournal) countPendingTransfer() {
+	if logs, err := log.CollectExistedLogs(j.config); err == nil {
+		atomic.StoreInt64(&j.pendingTransfer, int64(len(logs)))
+	}
+}
+
+func (j *fileJournal) saveState() {
+	j.stateLock.Lock()
+	defer j.stateLock.Unlock()
+
+	state := journalState{}
+	if j.chain != nil {
+		j.chain.lock.Lock()
+		state.Seq = atomic.LoadUint64(&j.seq)
+		state.PrevHash = j.chain.prevHash
+		j.chain.lock.Unlock()
+	} else {
+		state.Seq = atomic.LoadUint64(&j.seq)
+	}
+	if err := saveState(j.config, state); err != nil {
+		logger.Warnf(codes.JournalingError, "could not save journal state: %v", err)
+	}
 }
 
 func NewFileJournal(loggerConfig log.Config, moduleName, host string, opts ...Option) Journal {
 	j := &fileJournal{
+		config:     loggerConfig,
+		counter:    newEntryCounter(),
 		moduleName: moduleName,
 		host:       host,
+		minLevel:   loggerConfig.GetMinLevel(),
+	}
+	if loggerConfig.MinLevel != "" {
+		if _, err := entry.ParseLevel(loggerConfig.MinLevel); err != nil {
+			logger.Errorf(codes.JournalingError, "invalid min level, entries of all levels are journaled: %v", err)
+		}
 	}
 
 	for _, opt := range opts {
 		opt(j)
 	}
 
-	j.log = log.NewDefaultLogger(loggerConfig, log.WithAfterRotation(j.afterRotation))
+	seq, record := lastRecord(loggerConfig)
+	j.seq = seq
+	if j.chain != nil && record != nil {
+		j.chain.prevHash = entry.ChainHash(j.chain.key, record)
+	}
+	// files with the last entries may be already transferred and removed
+	if state := readState(loggerConfig); state.Seq > j.seq {
+		j.seq = state.Seq
+		if j.chain != nil {
+			j.chain.prevHash = state.PrevHash
+		}
+	}
+
+	// both payloads together must fit into log file, otherwise entry will be rejected by logger
+	maxPayloadSize := int(loggerConfig.GetMaxSizeInBytes()/2) - payloadReserve
+	if maxPayloadSize > 0 && (j.maxPayloadSize <= 0 || j.maxPayloadSize > maxPayloadSize) {
+		j.maxPayloadSize = maxPayloadSize
+	}
+
+	// logger notifies about rotation of file left by previous process and removal of expired files
+	j.countPendingTransfer()
+	j.guard = log.NewDiskGuard(loggerConfig, j.onDiskSpaceEvent)
+	j.log = log.NewDefaultLogger(
+		loggerConfig,
+		log.WithAfterRotation(j.onRotation),
+		log.WithAfterRemoval(j.onRemoval),
+		log.WithModuleInfo(moduleName, host),
+	)
 
 	return j
 }
@@ -0,0 +1,441 @@
+package journal
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"github.com/integration-system/isp-journal/encryption"
+	"github.com/integration-system/isp-journal/entry"
+	"github.com/integration-system/isp-journal/log"
+	"github.com/integration-system/isp-journal/search"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileJournalTruncatesPayload(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "journal")
+	a.NoError(err)
+	defer os.RemoveAll(dir)
+
+	cfg := log.Config{
+		Filename:  filepath.Join(dir, "test.log"),
+		MaxSizeMb: 1,
+	}
+	j := NewFileJournal(cfg, "module", "host", WithMaxPayloadSize(10))
+	a.NoError(j.Info("short", []byte("request"), []byte("response")))
+	a.NoError(j.Info("long", bytes.Repeat([]byte{'a'}, 20), []byte("response")))
+	a.NoError(j.Close())
+
+	f, err := os.Open(cfg.GetFilename())
+	a.NoError(err)
+	defer f.Close()
+
+	e, err := entry.UnmarshalNext(f)
+	a.NoError(err)
+	a.False(e.Truncated)
+	a.Equal("request", string(e.Request))
+	a.EqualValues(0, e.RequestLength)
+
+	e, err = entry.UnmarshalNext(f)
+	a.NoError(err)
+	a.True(e.Truncated)
+	a.Len(e.Request, 10)
+	a.EqualValues(20, e.RequestLength)
+	a.EqualValues(8, e.ResponseLength)
+}
+
+func TestFileJournalRecoversSeq(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "journal")
+	a.NoError(err)
+	defer os.RemoveAll(dir)
+
+	cfg := log.Config{
+		Filename:  filepath.Join(dir, "test.log"),
+		MaxSizeMb: 1,
+		Compress:  true,
+	}
+	j := NewFileJournal(cfg, "module", "host")
+	a.NoError(j.Info("event", nil, nil))
+	a.NoError(j.Info("event", nil, nil))
+	a.NoError(j.Rotate())
+	a.NoError(j.Close())
+
+	// active file is empty, sequence is recovered from rotated one
+	j = NewFileJournal(cfg, "module", "host")
+	a.NoError(j.Info("event", nil, nil))
+	a.NoError(j.Close())
+
+	j = NewFileJournal(cfg, "module", "host")
+	a.NoError(j.Info("event", nil, nil))
+	a.NoError(j.Close())
+
+	f, err := os.Open(cfg.GetFilename())
+	a.NoError(err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	a.NoError(err)
+
+	// file left by previous process is rotated on start, so active file contains the last entry only
+	e, err := entry.UnmarshalNext(gz)
+	a.NoError(err)
+	a.EqualValues(4, e.Seq)
+	a.Len(e.Id, 26)
+	_, err = entry.UnmarshalNext(gz)
+	a.Equal(io.EOF, err)
+
+	logs, err := log.CollectExistedLogs(cfg)
+	a.NoError(err)
+	a.Len(logs, 2)
+}
+
+func TestFileJournalRecoversStateAfterTransfer(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "journal")
+	a.NoError(err)
+	defer os.RemoveAll(dir)
+	transferDir, err := ioutil.TempDir("", "transfer")
+	a.NoError(err)
+	defer os.RemoveAll(transferDir)
+
+	cfg := log.Config{
+		Filename:  filepath.Join(dir, "test.log"),
+		MaxSizeMb: 1,
+	}
+	key := []byte("secret")
+	j := NewFileJournal(cfg, "module", "host", WithHashChain(key))
+	a.NoError(j.Info("event", []byte("request"), nil))
+	a.NoError(j.Info("event", []byte("request"), nil))
+	a.NoError(j.Rotate())
+	a.NoError(j.Close())
+
+	// rotated file is transferred and removed before restart
+	logs, err := log.CollectExistedLogs(cfg)
+	a.NoError(err)
+	a.Len(logs, 1)
+	transferred := filepath.Join(transferDir, logs[0].Name())
+	a.NoError(os.Rename(logs[0].FullPath, transferred))
+
+	j = NewFileJournal(cfg, "module", "host", WithHashChain(key))
+	a.NoError(j.Info("event", []byte("request"), nil))
+	a.NoError(j.Close())
+
+	f, err := os.Open(cfg.GetFilename())
+	a.NoError(err)
+	defer f.Close()
+	r, err := search.NewDecodedReader(f)
+	a.NoError(err)
+	e, err := entry.UnmarshalNext(r)
+	a.NoError(err)
+	a.EqualValues(3, e.Seq)
+
+	chainBreak, err := search.VerifyChain(key, []string{transferred, cfg.GetFilename()})
+	a.NoError(err)
+	a.Nil(chainBreak)
+}
+
+func TestJournalState(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "journal")
+	a.NoError(err)
+	defer os.RemoveAll(dir)
+
+	cfg := log.Config{Filename: filepath.Join(dir, "test.log")}
+	a.Equal(journalState{}, readState(cfg))
+
+	wg := sync.WaitGroup{}
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func(seq uint64) {
+			defer wg.Done()
+			a.NoError(saveState(cfg, journalState{Seq: seq, PrevHash: bytes.Repeat([]byte{byte(seq)}, 32)}))
+		}(uint64(i))
+	}
+	wg.Wait()
+	state := readState(cfg)
+	a.True(state.Seq > 0)
+	a.Equal(bytes.Repeat([]byte{byte(state.Seq)}, 32), state.PrevHash)
+	files, err := ioutil.ReadDir(dir)
+	a.NoError(err)
+	a.Len(files, 1)
+
+	a.NoError(ioutil.WriteFile(stateFilename(cfg), []byte(`{"seq":`), 0644))
+	a.Equal(journalState{}, readState(cfg))
+}
+
+func TestFileJournalHashChain(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "journal")
+	a.NoError(err)
+	defer os.RemoveAll(dir)
+
+	cfg := log.Config{
+		Filename:  filepath.Join(dir, "test.log"),
+		MaxSizeMb: 1,
+	}
+	key := []byte("secret")
+	j := NewFileJournal(cfg, "module", "host", WithHashChain(key))
+	a.NoError(j.Info("event", []byte("request"), nil))
+	a.NoError(j.Info("event", []byte("request"), nil))
+	a.NoError(j.Rotate())
+	a.NoError(j.Close())
+
+	// chain continues after restart
+	j = NewFileJournal(cfg, "module", "host", WithHashChain(key))
+	a.NoError(j.Info("event", []byte("request"), nil))
+	a.NoError(j.Close())
+
+	logs, err := log.CollectExistedLogs(cfg)
+	a.NoError(err)
+	a.Len(logs, 1)
+	chainBreak, err := search.VerifyChain(key, []string{logs[0].FullPath, cfg.GetFilename()})
+	a.NoError(err)
+	a.Nil(chainBreak)
+
+	data, err := ioutil.ReadFile(logs[0].FullPath)
+	a.NoError(err)
+	a.NoError(ioutil.WriteFile(logs[0].FullPath, bytes.Replace(data, []byte("request"), []byte("changed"), 1), 0644))
+	chainBreak, err = search.VerifyChain(key, []string{logs[0].FullPath, cfg.GetFilename()})
+	a.NoError(err)
+	a.Equal(1, chainBreak.Record)
+	a.Equal(logs[0].FullPath, chainBreak.File)
+}
+
+func TestFileJournalHashChainBatched(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "journal")
+	a.NoError(err)
+	defer os.RemoveAll(dir)
+
+	cfg := log.Config{
+		Filename:       filepath.Join(dir, "test.log"),
+		MaxSizeMb:      1,
+		BatchSize:      1024 * 1024,
+		BatchTimeoutMs: 200,
+	}
+	key := []byte("secret")
+	j := NewFileJournal(cfg, "module", "host", WithHashChain(key))
+
+	// parallel chained writes wait for the same batch instead of one batch each
+	startedAt := time.Now()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.NoError(j.Info("event", []byte("request"), nil))
+		}()
+	}
+	wg.Wait()
+	a.True(time.Since(startedAt) < 1*time.Second)
+	a.NoError(j.Close())
+
+	chainBreak, err := search.VerifyChain(key, []string{cfg.GetFilename()})
+	a.NoError(err)
+	a.Nil(chainBreak)
+	f, err := os.Open(cfg.GetFilename())
+	a.NoError(err)
+	defer f.Close()
+	r, err := search.NewDecodedReader(f)
+	a.NoError(err)
+	seqs := make([]uint64, 0)
+	for {
+		e, err := entry.UnmarshalNext(r)
+		if err != nil {
+			break
+		}
+		seqs = append(seqs, e.Seq)
+	}
+	a.Equal([]uint64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, seqs)
+}
+
+func TestFileJournalEncryption(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "journal")
+	a.NoError(err)
+	defer os.RemoveAll(dir)
+
+	key := bytes.Repeat([]byte{7}, 32)
+	cfg := log.Config{
+		Filename:      filepath.Join(dir, "test.log"),
+		MaxSizeMb:     1,
+		Compress:      true,
+		EncryptionKey: base64.StdEncoding.EncodeToString(key),
+	}
+	chainKey := []byte("chain")
+	j := NewFileJournal(cfg, "module", "host", WithHashChain(chainKey))
+	a.NoError(j.Info("event", []byte("secret request"), nil))
+	a.NoError(j.Close())
+
+	// sequence is recovered from encrypted file
+	j = NewFileJournal(cfg, "module", "host", WithHashChain(chainKey))
+	a.NoError(j.Info("event", []byte("secret request"), nil))
+	a.NoError(j.Rotate())
+	a.NoError(j.Close())
+
+	// file of the first journal is rotated on start of the second one
+	logs, err := log.CollectExistedLogs(cfg)
+	a.NoError(err)
+	a.Len(logs, 2)
+	sort.Slice(logs, func(i, j int) bool {
+		return logs[i].CreatedAt.Before(logs[j].CreatedAt)
+	})
+
+	for i, l := range logs {
+		a.True(l.IsEncrypted())
+		data, err := ioutil.ReadFile(l.FullPath)
+		a.NoError(err)
+		a.False(bytes.Contains(data, []byte("secret")))
+
+		_, err = search.NewDecodedReader(bytes.NewReader(data))
+		a.Equal(encryption.ErrNoKey, err)
+
+		r, err := search.NewDecodedReader(bytes.NewReader(data), search.WithDecryptionKey(key))
+		a.NoError(err)
+		e, err := entry.UnmarshalNext(r)
+		a.NoError(err)
+		a.EqualValues(i+1, e.Seq)
+		a.Equal("secret request", string(e.Request))
+	}
+
+	files := []string{logs[0].FullPath, logs[1].FullPath}
+	_, err = search.VerifyChain(chainKey, files)
+	a.Error(err)
+	chainBreak, err := search.VerifyChain(chainKey, files, search.WithDecryptionKey(key))
+	a.NoError(err)
+	a.Nil(chainBreak)
+}
+
+func TestFileJournalFileHeader(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "journal")
+	a.NoError(err)
+	defer os.RemoveAll(dir)
+
+	cfg := log.Config{
+		Filename:   filepath.Join(dir, "test.log"),
+		MaxSizeMb:  1,
+		Codec:      "zstd",
+		FileHeader: true,
+	}
+	j := NewFileJournal(cfg, "module", "host")
+	a.NoError(j.Info("event", []byte("request"), nil))
+	a.NoError(j.Close())
+
+	// file of the first journal is finalized with trailer on start of the second one
+	j = NewFileJournal(cfg, "module", "host")
+	a.NoError(j.Error("event", nil, nil, errors.New("error")))
+	a.NoError(j.Rotate())
+	a.NoError(j.Close())
+
+	logs, err := log.CollectExistedLogs(cfg)
+	a.NoError(err)
+	a.Len(logs, 2)
+	sort.Slice(logs, func(i, j int) bool {
+		return logs[i].CreatedAt.Before(logs[j].CreatedAt)
+	})
+
+	for i, level := range []string{"OK", "ERROR"} {
+		f, err := os.Open(logs[i].FullPath)
+		a.NoError(err)
+		header, trailer, err := entry.ReadFileSummary(f, logs[i].Size())
+		a.NoError(err)
+		a.Equal("module", header.ModuleName)
+		a.Equal("host", header.Host)
+		a.Equal("zstd", header.Codec)
+		a.EqualValues(1, trailer.Entries)
+		a.Equal(map[string]int64{level: 1}, trailer.Levels)
+		a.NotEmpty(trailer.MinTime)
+		a.NotEmpty(trailer.MaxTime)
+
+		r, err := search.NewDecodedReader(f)
+		a.NoError(err)
+		e, err := entry.UnmarshalNext(r)
+		a.NoError(err)
+		a.EqualValues(i+1, e.Seq)
+		_, err = entry.UnmarshalNext(r)
+		a.Equal(io.EOF, err)
+		a.NoError(f.Close())
+	}
+}
+
+func TestFileJournalDegradeMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("free space check is not supported")
+	}
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "journal")
+	a.NoError(err)
+	defer os.RemoveAll(dir)
+
+	for _, mode := range []log.DegradeMode{log.DegradeStop, log.DegradeDropNonError, log.DegradeDropPayload} {
+		cfg := log.Config{
+			Filename:       filepath.Join(dir, string(mode)+".log"),
+			MaxSizeMb:      1,
+			MinFreeSpaceMb: 1 << 40,
+			DegradeMode:    string(mode),
+		}
+		events := make([]log.DiskSpaceEvent, 0)
+		j := NewFileJournal(cfg, "module", "host", WithDiskSpaceEvents(func(event log.DiskSpaceEvent) {
+			events = append(events, event)
+		}))
+		a.Len(events, 1)
+		a.Equal(mode, events[0].Mode)
+
+		a.NoError(j.Info("event", []byte("request"), []byte("response")))
+		a.NoError(j.Error("event", []byte("request"), nil, errors.New("error")))
+		stats := j.(StatsProvider).Stats()
+		a.Equal(mode, stats.DegradeMode)
+		a.NoError(j.Close())
+
+		written := make([]*entry.Entry, 0)
+		if f, err := os.Open(cfg.GetFilename()); err == nil {
+			for {
+				e, err := entry.UnmarshalNext(f)
+				if err != nil {
+					break
+				}
+				written = append(written, e)
+			}
+			a.NoError(f.Close())
+		}
+
+		switch mode {
+		case log.DegradeStop:
+			a.Empty(written)
+			a.EqualValues(2, stats.DegradeDropped)
+		case log.DegradeDropNonError:
+			a.Len(written, 1)
+			a.Equal("ERROR", written[0].Level)
+			a.EqualValues(1, stats.DegradeDropped)
+		case log.DegradeDropPayload:
+			a.Len(written, 2)
+			for _, e := range written {
+				a.Empty(e.Request)
+				a.Empty(e.Response)
+				a.True(e.Truncated)
+				a.EqualValues(len("request"), e.RequestLength)
+			}
+			a.EqualValues(0, stats.DegradeDropped)
+		}
+	}
+}
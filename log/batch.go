@@ -0,0 +1,97 @@
+package log
+
+import (
+	"sync"
+	"time"
+)
+
+type batch struct {
+	records [][]byte
+	size    int
+	done    chan struct{}
+	err     error
+}
+
+// batcher collects records from concurrent writers and commits them together.
+// Every writer waits until its batch is committed and receives commit error.
+type batcher struct {
+	lock    sync.Mutex
+	cur     *batch
+	timer   *time.Timer
+	maxSize int
+	timeout time.Duration
+	commit  func(records [][]byte) error
+}
+
+func (b *batcher) write(p []byte) error {
+	return b.append(p).wait()
+}
+
+// append adds record to current batch, records are committed in order of append calls
+func (b *batcher) append(p []byte) *batch {
+	b.lock.Lock()
+	if b.cur == nil {
+		cur := &batch{done: make(chan struct{})}
+		b.cur = cur
+		b.timer = time.AfterFunc(b.timeout, func() {
+			b.flush(cur)
+		})
+	}
+	cur := b.cur
+	cur.records = append(cur.records, p)
+	cur.size += len(p)
+	if cur.size >= b.maxSize {
+		b.cur = nil
+		b.timer.Stop()
+		b.lock.Unlock()
+		b.commitBatch(cur)
+	} else {
+		b.lock.Unlock()
+	}
+	return cur
+}
+
+// wait blocks until batch is committed
+func (b *batch) wait() error {
+	<-b.done
+	return b.err
+}
+
+// flush commits batch if it was not committed yet by size limit
+func (b *batcher) flush(cur *batch) {
+	b.lock.Lock()
+	if b.cur != cur {
+		b.lock.Unlock()
+		return
+	}
+	b.cur = nil
+	b.lock.Unlock()
+
+	b.commitBatch(cur)
+}
+
+func (b *batcher) close() {
+	b.lock.Lock()
+	cur := b.cur
+	if cur != nil {
+		b.timer.Stop()
+	}
+	b.lock.Unlock()
+
+	if cur != nil {
+		b.flush(cur)
+	}
+}
+
+func (b *batcher) commitBatch(cur *batch) {
+	cur.err = b.commit(cur.records)
+	close(cur.done)
+}
+
+func newBatcher(maxSize int, timeout time.Duration, commit func(records [][]byte) error) *batcher {
+	return &batcher{
+		maxSize: maxSize,
+		timeout: timeout,
+		commit:  commit,
+	}
+}
@@ -1,17 +1,54 @@
 package log
 
 import (
+	"encoding/base64"
+	"fmt"
+	"github.com/integration-system/isp-journal/codec"
+	"github.com/integration-system/isp-journal/entry"
 	"os"
 	"path/filepath"
 	"time"
 )
 
+const (
+	defaultBatchTimeout           = 10 * time.Millisecond
+	defaultFlushInterval          = time.Second
+	defaultFreeSpaceCheckInterval = 10 * time.Second
+
+	// DurabilityWrite flushes and syncs file after every write
+	DurabilityWrite = "write"
+	// DurabilityInterval flushes and syncs file periodically if something was written
+	DurabilityInterval = "interval"
+	// DurabilityRotation flushes and syncs file on rotation and close only
+	DurabilityRotation = "rotation"
+)
+
 type Config struct {
-	Filename        string `schema:"Имя файла,путь до файла в который будут записываться логи"`
-	MaxSizeMb       int    `schema:"Максимальный размер файла,ограничение по размеру файла после достижения которого логи будут записываться в новый файл"`
-	RotateTimeoutMs int    `schema:"Время чередования файлов,ограничение по времени записи после достижения которого логи будут записываться в новый файл"`
-	Compress        bool   `schema:"Сжатие логов,архивирует файлы в gzip"`
-	BufferSize      int    `schema:"Размер буфера,при указании разбивает данные и записывает их в файл по частям"`
+	Filename         string `schema:"Имя файла,путь до файла в который будут записываться логи"`
+	MaxSizeMb        int    `schema:"Максимальный размер файла,ограничение по размеру файла после достижения которого логи будут записываться в новый файл"`
+	RotateTimeoutMs  int    `schema:"Время чередования файлов,ограничение по времени записи после достижения которого логи будут записываться в новый файл"`
+	RotationSchedule string `schema:"Расписание чередования файлов,hourly, daily, daily HH:MM или период, например 15m, отсчитываемый от полуночи, при указании используется вместо времени чередования"`
+	RotationTimezone string `schema:"Часовой пояс расписания,например Europe/Moscow, по умолчанию UTC"`
+	Compress         bool   `schema:"Сжатие логов,архивирует файлы в gzip, если не указан алгоритм сжатия"`
+	Codec            string `schema:"Алгоритм сжатия,none, gzip, zstd, snappy или lz4, при пустом значении используется настройка 'Сжатие логов'"`
+	CompressionLevel int    `schema:"Уровень сжатия,0 - уровень по умолчанию, для gzip 1-9, для zstd 1-22, для lz4 чем больше тем сильнее сжатие, для snappy не используется"`
+	MaxBackups       int    `schema:"Максимальное количество файлов,при превышении самые старые файлы журналов удаляются после чередования, 0 - без ограничения"`
+	MaxAgeDays       int    `schema:"Максимальный возраст файлов,файлы журналов старше указанного количества дней удаляются после чередования, 0 - без ограничения"`
+	MaxTotalSizeMb   int    `schema:"Максимальный общий размер файлов,при превышении самые старые файлы журналов удаляются после чередования, 0 - без ограничения"`
+	BufferSize       int    `schema:"Размер буфера,при указании разбивает данные и записывает их в файл по частям"`
+	BatchSize        int    `schema:"Размер пакета,при указании записи от параллельных писателей объединяются в пакет указанного размера в байтах и записываются в файл одной операцией"`
+	BatchTimeoutMs   int    `schema:"Время накопления пакета,максимальное время ожидания заполнения пакета, по умолчанию 10 мс"`
+	MinLevel         string `schema:"Минимальный уровень,записи с уровнем ниже указанного не журналируются, уровни по возрастанию: DEBUG, OK, WARN, ERROR, FATAL"`
+	EncryptionKey    string `schema:"Ключ шифрования,ключ AES в base64 длиной 16, 24 или 32 байта, при указании файлы журналов шифруются"`
+	RecordChecksum   bool   `schema:"Контрольные суммы записей,при включении к каждой записи добавляется CRC32C, что позволяет обнаруживать и пропускать повреждённые записи при чтении"`
+	Durability       string `schema:"Режим сохранности,write - сброс буферов и fsync после каждой записи, interval - периодически, rotation - только при чередовании и закрытии файла (по умолчанию)"`
+	FlushIntervalMs  int    `schema:"Интервал сброса на диск,для режима сохранности interval, по умолчанию 1000 мс"`
+	MinFreeSpaceMb   int    `schema:"Минимальное свободное место на диске,при меньшем свободном месте журнал переходит в режим деградации, 0 - без проверки"`
+	DegradeMode      string `schema:"Режим деградации,drop_non_error - сохраняются только записи с уровнем ERROR и выше (по умолчанию), drop_payload - записи сохраняются без запросов и ответов, stop - журналирование останавливается"`
+	FreeSpaceCheckMs int    `schema:"Интервал проверки свободного места,по умолчанию 10000 мс"`
+	FileHeader       bool   `schema:"Заголовок файла,при включении в начало файла записывается заголовок с описанием формата, а при чередовании в конец файла записывается сводка по записям, что позволяет поиску пропускать неподходящие файлы"`
+	// EncryptionKeyProvider is used instead of EncryptionKey if set, it is called on every file opening
+	EncryptionKeyProvider func() ([]byte, error) `json:"-"`
 }
 
 func (c Config) GetFilename() string {
@@ -21,10 +58,7 @@ func (c Config) GetFilename() string {
 	} else {
 		name = c.Filename
 	}
-	if c.IsCompress() {
-		name += ".gz"
-	}
-	return name
+	return name + c.GetCodec().Extension()
 }
 
 func (c Config) GetMaxSizeInBytes() int64 {
@@ -35,8 +69,46 @@ func (c Config) GetRotateTimeout() time.Duration {
 	return time.Duration(c.RotateTimeoutMs) * time.Millisecond
 }
 
+func (c Config) GetMaxAge() time.Duration {
+	return time.Duration(c.MaxAgeDays) * 24 * time.Hour
+}
+
+func (c Config) GetMaxTotalSizeInBytes() int64 {
+	return int64(c.MaxTotalSizeMb) * 1024 * 1024
+}
+
+// HasRetention reports whether rotated files are removed by any limit
+func (c Config) HasRetention() bool {
+	return c.MaxBackups > 0 || c.MaxAgeDays > 0 || c.MaxTotalSizeMb > 0
+}
+
+// rotationSchedule returns nil if RotationSchedule is not set
+func (c Config) rotationSchedule() (*schedule, error) {
+	if c.RotationSchedule == "" {
+		return nil, nil
+	}
+	return parseSchedule(c.RotationSchedule, c.RotationTimezone)
+}
+
 func (c Config) IsCompress() bool {
-	return c.Compress
+	return c.GetCodec() != codec.None
+}
+
+// GetCodec returns gzip or none according to Compress if Codec is not set or invalid
+func (c Config) GetCodec() codec.Codec {
+	if c.Codec != "" {
+		if cd, err := codec.Parse(c.Codec); err == nil {
+			return cd
+		}
+	}
+	if c.Compress {
+		return codec.Gzip
+	}
+	return codec.None
+}
+
+func (c Config) GetCompressionLevel() int {
+	return c.CompressionLevel
 }
 
 func (c Config) IsBuffered() bool {
@@ -47,6 +119,83 @@ func (c Config) GetBufferSize() int {
 	return c.BufferSize
 }
 
+func (c Config) IsBatched() bool {
+	return c.BatchSize > 0
+}
+
+func (c Config) GetBatchSize() int {
+	return c.BatchSize
+}
+
+func (c Config) GetBatchTimeout() time.Duration {
+	if c.BatchTimeoutMs <= 0 {
+		return defaultBatchTimeout
+	}
+	return time.Duration(c.BatchTimeoutMs) * time.Millisecond
+}
+
+// GetDurability returns DurabilityRotation if Durability is not set or invalid
+func (c Config) GetDurability() string {
+	switch c.Durability {
+	case DurabilityWrite, DurabilityInterval:
+		return c.Durability
+	default:
+		return DurabilityRotation
+	}
+}
+
+func (c Config) GetFlushInterval() time.Duration {
+	if c.FlushIntervalMs <= 0 {
+		return defaultFlushInterval
+	}
+	return time.Duration(c.FlushIntervalMs) * time.Millisecond
+}
+
+func (c Config) GetMinFreeSpaceInBytes() uint64 {
+	if c.MinFreeSpaceMb <= 0 {
+		return 0
+	}
+	return uint64(c.MinFreeSpaceMb) * 1024 * 1024
+}
+
+// GetDegradeMode returns DegradeDropNonError if DegradeMode is not set or invalid
+func (c Config) GetDegradeMode() DegradeMode {
+	switch mode := DegradeMode(c.DegradeMode); mode {
+	case DegradeDropPayload, DegradeStop:
+		return mode
+	default:
+		return DegradeDropNonError
+	}
+}
+
+func (c Config) GetFreeSpaceCheckInterval() time.Duration {
+	if c.FreeSpaceCheckMs <= 0 {
+		return defaultFreeSpaceCheckInterval
+	}
+	return time.Duration(c.FreeSpaceCheckMs) * time.Millisecond
+}
+
+// GetMinLevel returns empty level if MinLevel is not set or invalid
+func (c Config) GetMinLevel() entry.Level {
+	level, _ := entry.ParseLevel(c.MinLevel)
+	return level
+}
+
+func (c Config) IsEncrypted() bool {
+	return c.EncryptionKeyProvider != nil || c.EncryptionKey != ""
+}
+
+func (c Config) GetEncryptionKey() ([]byte, error) {
+	if c.EncryptionKeyProvider != nil {
+		return c.EncryptionKeyProvider()
+	}
+	key, err := base64.StdEncoding.DecodeString(c.EncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %v", err)
+	}
+	return key, nil
+}
+
 func (c Config) GetDirectory() string {
 	return filepath.Dir(c.GetFilename())
 }
@@ -0,0 +1,131 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type DegradeMode string
+
+const (
+	// DegradeNone is normal mode
+	DegradeNone DegradeMode = ""
+	// DegradeDropNonError drops entries with level lower than ERROR
+	DegradeDropNonError DegradeMode = "drop_non_error"
+	// DegradeDropPayload writes entries without request and response
+	DegradeDropPayload DegradeMode = "drop_payload"
+	// DegradeStop drops all entries
+	DegradeStop DegradeMode = "stop"
+)
+
+// DiskSpaceEvent is emitted on every switch of degraded mode, Mode is DegradeNone if guard returned to normal mode
+type DiskSpaceEvent struct {
+	Mode      DegradeMode
+	PrevMode  DegradeMode
+	FreeBytes uint64
+}
+
+// DiskGuard periodically checks free space on volume of log directory and switches to degraded mode
+// when it falls below MinFreeSpaceMb. Normal mode is restored when free space exceeds threshold by 10%
+// to prevent switching back and forth. Methods of nil guard are no-op.
+type DiskGuard struct {
+	c         Config
+	mode      atomic.Value
+	onEvent   func(DiskSpaceEvent)
+	freeSpace func(dir string) (uint64, error)
+	closeChan chan struct{}
+	closeOnce sync.Once
+}
+
+// Mode returns current degraded mode
+func (g *DiskGuard) Mode() DegradeMode {
+	if g == nil {
+		return DegradeNone
+	}
+	return g.mode.Load().(DegradeMode)
+}
+
+func (g *DiskGuard) Close() {
+	if g == nil {
+		return
+	}
+	g.closeOnce.Do(func() {
+		close(g.closeChan)
+	})
+}
+
+func (g *DiskGuard) run() {
+	ticker := time.NewTicker(g.c.GetFreeSpaceCheckInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			g.check()
+		case <-g.closeChan:
+			return
+		}
+	}
+}
+
+func (g *DiskGuard) check() {
+	free, err := g.freeSpace(existedDir(g.c.GetDirectory()))
+	if err != nil {
+		// mode is kept if free space is unknown
+		return
+	}
+
+	prevMode := g.Mode()
+	mode := prevMode
+	minFree := g.c.GetMinFreeSpaceInBytes()
+	if free < minFree {
+		mode = g.c.GetDegradeMode()
+	} else if free >= minFree+minFree/10 {
+		mode = DegradeNone
+	}
+	if mode == prevMode {
+		return
+	}
+	g.mode.Store(mode)
+	if g.onEvent != nil {
+		g.onEvent(DiskSpaceEvent{Mode: mode, PrevMode: prevMode, FreeBytes: free})
+	}
+}
+
+// existedDir returns dir or its nearest existed parent, since log directory is created on first write
+func existedDir(dir string) string {
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// NewDiskGuard returns nil if MinFreeSpaceMb is not set, free space is checked immediately
+// and then periodically until guard is closed, onEvent may be nil
+func NewDiskGuard(c Config, onEvent func(DiskSpaceEvent)) *DiskGuard {
+	return newDiskGuard(c, onEvent, diskFreeSpace)
+}
+
+func newDiskGuard(c Config, onEvent func(DiskSpaceEvent), freeSpace func(dir string) (uint64, error)) *DiskGuard {
+	if c.GetMinFreeSpaceInBytes() <= 0 {
+		return nil
+	}
+	g := &DiskGuard{
+		c:         c,
+		onEvent:   onEvent,
+		freeSpace: freeSpace,
+		closeChan: make(chan struct{}),
+	}
+	g.mode.Store(DegradeNone)
+	g.check()
+	go g.run()
+	return g
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package log
+
+import (
+	"errors"
+)
+
+// diskFreeSpace is not supported, so disk guard never switches to degraded mode
+func diskFreeSpace(dir string) (uint64, error) {
+	return 0, errors.New("free space check is not supported")
+}
@@ -0,0 +1,17 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package log
+
+import (
+	"syscall"
+)
+
+// diskFreeSpace returns space available to unprivileged user on volume of dir
+func diskFreeSpace(dir string) (uint64, error) {
+	st := syscall.Statfs_t{}
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
@@ -2,10 +2,13 @@ package log
 
 import (
 	"bufio"
-	"compress/gzip"
 	"errors"
 	"fmt"
 	io2 "github.com/integration-system/isp-io"
+	"github.com/integration-system/isp-journal/codec"
+	"github.com/integration-system/isp-journal/encryption"
+	"github.com/integration-system/isp-journal/entry"
+	"io"
 	"io/ioutil"
 	"os"
 	"path"
@@ -23,6 +26,7 @@ type LogFile struct {
 	CreatedAt  time.Time
 	FullPath   string
 	Compressed bool
+	Codec      codec.Codec
 }
 
 func CollectExistedLogs(loggerConfig Config) ([]LogFile, error) {
@@ -39,11 +43,14 @@ func CollectExistedLogs(loggerConfig Config) ([]LogFile, error) {
 			continue
 		}
 		if t, err := parseTimeFormFilename(f.Name(), prefix, ext); err == nil {
+			fullPath := path.Join(loggerConfig.GetDirectory(), f.Name())
+			fileCodec := codec.ByExtension(path.Ext(f.Name()))
 			logFiles = append(logFiles, LogFile{
-				Compressed: path.Ext(f.Name()) == ".gz",
+				Compressed: fileCodec != codec.None,
+				Codec:      fileCodec,
 				FileInfo:   f,
 				CreatedAt:  t,
-				FullPath:   path.Join(loggerConfig.GetDirectory(), f.Name()),
+				FullPath:   fullPath,
 			})
 		}
 		// error parsing means that the suffix at the end was not generated
@@ -66,6 +73,7 @@ func MakeLogFile(c Config, filepath string) (*LogFile, error) {
 				CreatedAt:  t,
 				FileInfo:   info,
 				Compressed: c.IsCompress(),
+				Codec:      c.GetCodec(),
 			}, nil
 		}
 	}
@@ -82,9 +90,44 @@ func parseTimeFormFilename(filename, prefix, ext string) (time.Time, error) {
 	return time.Parse(backupTimeFormat, ts)
 }
 
+// filePipe is write pipe to log file, which can be flushed to disk without closing
+type filePipe struct {
+	io2.WritePipe
+	file *os.File
+	// flushers are ordered from the outermost stage
+	flushers []interface{ Flush() error }
+}
+
+// Sync flushes all stages including compression stream and commits file to disk
+func (p *filePipe) Sync() error {
+	for _, f := range p.flushers {
+		if err := f.Flush(); err != nil {
+			return err
+		}
+	}
+	return p.file.Sync()
+}
+
+// syncedFile is the last stage of pipe, file is committed to disk on rotation and close in any durability mode
+type syncedFile struct {
+	*os.File
+}
+
+func (f syncedFile) Close() error {
+	_ = f.Sync()
+	return f.File.Close()
+}
+
+func (p *filePipe) unshift(w io.Writer) {
+	p.Unshift(w)
+	if f, ok := w.(interface{ Flush() error }); ok {
+		p.flushers = append([]interface{ Flush() error }{f}, p.flushers...)
+	}
+}
+
 // openNewAndRenameExisted opens a new log file for writing, moving any old log file out of the
 // way.  This methods assumes the file has already been closed.
-func openNewAndRenameExisted(c Config) (io2.WritePipe, string, error) {
+func openNewAndRenameExisted(c Config, header *entry.FileHeader) (*filePipe, string, error) {
 	err := os.MkdirAll(c.GetDirectory(), 0755)
 	if err != nil {
 		return nil, "", fmt.Errorf("can't make directories for new logfile: %s", err)
@@ -109,7 +152,7 @@ func openNewAndRenameExisted(c Config) (io2.WritePipe, string, error) {
 		//}
 	}
 
-	pipe, err := makePipe(c, name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	pipe, err := makePipe(c, name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode, header)
 	if err != nil {
 		return nil, "", err
 	}
@@ -117,32 +160,86 @@ func openNewAndRenameExisted(c Config) (io2.WritePipe, string, error) {
 	return pipe, newname, nil
 }
 
-func makePipe(c Config, srcFile string, flag int, mode os.FileMode) (io2.WritePipe, error) {
+// makePipe writes header to the beginning of file if it is set, header is never compressed or encrypted
+func makePipe(c Config, srcFile string, flag int, mode os.FileMode, header *entry.FileHeader) (*filePipe, error) {
 	f, err := os.OpenFile(srcFile, flag, mode)
 	if err != nil {
 		return nil, err
 	}
-	p := io2.NewWritePipe(f)
+	if header != nil {
+		if err := entry.WriteFileHeader(f, header); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+	}
+	p := &filePipe{WritePipe: io2.NewWritePipe(syncedFile{f}), file: f}
+	// p.Last() returns file, so each stage wraps the previous one explicitly
+	var w io.Writer = f
 
 	if c.IsBuffered() {
-		bufWr := bufio.NewWriterSize(p.Last(), c.GetBufferSize())
-		p.Unshift(bufWr)
+		bufWr := bufio.NewWriterSize(w, c.GetBufferSize())
+		p.unshift(bufWr)
+		w = bufWr
+	}
+
+	if c.IsEncrypted() {
+		key, err := c.GetEncryptionKey()
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		encWr, err := encryption.NewWriter(w, key)
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		p.unshift(encWr)
+		w = encWr
 	}
 
 	if c.IsCompress() {
-		gzipWr := gzip.NewWriter(p.Last())
-		p.Unshift(gzipWr)
+		compressWr, err := codec.NewWriter(c.GetCodec(), w, c.GetCompressionLevel())
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		p.unshift(compressWr)
 	}
 
 	return p, nil
 }
 
+// IsEncrypted reads head of file on every call, files which were written before encryption was enabled are not encrypted
+func (f LogFile) IsEncrypted() bool {
+	return isEncryptedFile(f.FullPath)
+}
+
+func isEncryptedFile(filename string) bool {
+	f, err := os.Open(filename)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	buf := bufio.NewReader(f)
+	if _, err := entry.ReadFileHeader(buf); err != nil {
+		return false
+	}
+	head, _ := buf.Peek(len(encryption.Magic))
+	return encryption.IsEncrypted(head)
+}
+
 func getBackupFileName(name string) string {
 	dir := filepath.Dir(name)
 	filename := filepath.Base(name)
 	ext := filepath.Ext(filename)
 	prefix := filename[:len(filename)-len(ext)]
 
-	timestamp := time.Now().UTC().Format(backupTimeFormat)
-	return filepath.Join(dir, fmt.Sprintf("%s-%s%s", prefix, timestamp, ext))
+	// timestamp has millisecond precision, so existed backup must not be overwritten by fast consecutive rotations
+	for t := time.Now().UTC(); ; t = t.Add(time.Millisecond) {
+		backup := filepath.Join(dir, fmt.Sprintf("%s-%s%s", prefix, t.Format(backupTimeFormat), ext))
+		if _, err := os.Stat(backup); err != nil {
+			return backup
+		}
+	}
 }
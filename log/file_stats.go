@@ -0,0 +1,121 @@
+package log
+
+import (
+	"github.com/integration-system/isp-journal/codec"
+	"github.com/integration-system/isp-journal/entry"
+	"io"
+	"os"
+	"time"
+)
+
+// fileStats collects trailer of current file from written length prefixed entries
+type fileStats struct {
+	entries int64
+	minTime time.Time
+	maxTime time.Time
+	levels  map[string]int64
+}
+
+func newFileStats() *fileStats {
+	return &fileStats{levels: make(map[string]int64)}
+}
+
+// addRecords scans chunk of length prefixed entries, incomplete or invalid records are ignored
+func (s *fileStats) addRecords(p []byte) {
+	for len(p) >= 4 {
+		l, checksum := entry.ParseLengthPrefix(p)
+		n := 4 + l
+		if checksum {
+			n += 4
+		}
+		if n > len(p) {
+			return
+		}
+		s.addRecord(p[4 : 4+l])
+		p = p[n:]
+	}
+}
+
+func (s *fileStats) addRecord(record []byte) {
+	level, timeString, err := entry.ScanLevelAndTime(record)
+	if err != nil {
+		return
+	}
+	s.entries++
+	s.levels[level]++
+	t, err := entry.ParserTime(timeString)
+	if err != nil {
+		return
+	}
+	if s.minTime.IsZero() || t.Before(s.minTime) {
+		s.minTime = t
+	}
+	if t.After(s.maxTime) {
+		s.maxTime = t
+	}
+}
+
+func (s *fileStats) trailer() *entry.FileTrailer {
+	t := &entry.FileTrailer{
+		Entries: s.entries,
+		Levels:  s.levels,
+	}
+	if !s.minTime.IsZero() {
+		t.MinTime = entry.FormatTime(s.minTime)
+		t.MaxTime = entry.FormatTime(s.maxTime)
+	}
+	return t
+}
+
+// readFileStats restores stats of existed file, returns nil if file has no header
+func readFileStats(c Config, filename string) (*fileStats, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var keyProvider func() ([]byte, error)
+	if c.IsEncrypted() {
+		keyProvider = c.GetEncryptionKey
+	}
+	r, err := entry.NewFileReader(f, keyProvider, c.GetCodec(), codec.None)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	if r.Header() == nil {
+		return nil, nil
+	}
+	if info, err := f.Stat(); err != nil {
+		return nil, err
+	} else if trailer, err := entry.ReadFileTrailer(f, info.Size()); err != nil || trailer != nil {
+		// file was finalized but not renamed, entries must not be appended after trailer
+		return nil, err
+	}
+
+	s := newFileStats()
+	for {
+		record, err := entry.ReadNext(r)
+		if err == io.EOF {
+			return s, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.addRecord(record)
+	}
+}
+
+// appendTrailer writes trailer to the end of closed file
+func appendTrailer(filename string, trailer *entry.FileTrailer) error {
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if err := entry.WriteFileTrailer(f, trailer); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
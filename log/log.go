@@ -1,41 +1,128 @@
 package log
 
 import (
+	"errors"
 	"fmt"
-	io2 "github.com/integration-system/isp-io"
+	"github.com/integration-system/isp-journal/codec"
+	"github.com/integration-system/isp-journal/codes"
+	"github.com/integration-system/isp-journal/entry"
+	logger "github.com/integration-system/isp-log"
 	"io"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+var (
+	ErrLoggerClosed = errors.New("logger closed")
+)
+
 type Logger interface {
 	io.WriteCloser
+	// Append starts write of record and returns function waiting for write result.
+	// Records are written in order of Append calls, so caller may order records under its own lock
+	// without waiting for batch commit
+	Append(p []byte) (wait func() error)
 	Rotate() error
+	Stats() Stats
 }
 
 type defaultLogger struct {
+	// fields accessed atomically go first to be 64-bit aligned
+	stats loggerStats
+	// curSize is changed under wrLock, but atomically to be read by Stats
+	curSize int64
+
 	c Config
 
+	moduleName    string
+	host          string
 	afterRotation func(prevFile LogFile)
-	curSize       int64
+	afterRemoval  func(removed LogFile)
+	batcher       *batcher
 	wrLock        sync.Mutex
-	curWr         io2.WritePipe
+	curWr         *filePipe
 	rotateChan    chan struct{}
 	rotateErrChan chan error
 	closeChan     chan struct{}
+
+	// curFile is nil if current file has no header
+	curFile *fileStats
+	// dirty is set if current file has data which was not synced in interval durability mode
+	dirty bool
 }
 
 func (l *defaultLogger) Write(p []byte) (int, error) {
+	if l.batcher != nil {
+		if err := l.Append(p)(); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
 	l.wrLock.Lock()
 	defer l.wrLock.Unlock()
 
-	writeLen := int64(len(p))
-	if writeLen > l.c.GetMaxSizeInBytes() {
-		return 0, fmt.Errorf(
-			"write length %d exceeds maximum file size %d", writeLen, l.c.GetMaxSizeInBytes(),
-		)
+	return l.writeWithoutLock(p)
+}
+
+func (l *defaultLogger) Append(p []byte) func() error {
+	if l.batcher == nil {
+		_, err := l.Write(p)
+		return func() error {
+			return err
+		}
 	}
+	if err := l.checkWriteLen(len(p)); err != nil {
+		l.stats.onWrite(0, err)
+		return func() error {
+			return err
+		}
+	}
+	return l.batcher.append(p).wait
+}
+
+// writeBatch writes records as few large chunks, each chunk fits into current file
+func (l *defaultLogger) writeBatch(records [][]byte) error {
+	l.wrLock.Lock()
+	defer l.wrLock.Unlock()
+
+	total := 0
+	for _, p := range records {
+		total += len(p)
+	}
+	chunk := make([]byte, 0, total)
+	for _, p := range records {
+		if len(chunk) > 0 && l.curSize+int64(len(chunk)+len(p)) > l.c.GetMaxSizeInBytes() {
+			if _, err := l.writeWithoutLock(chunk); err != nil {
+				return err
+			}
+			chunk = chunk[:0]
+		}
+		chunk = append(chunk, p...)
+	}
+	if len(chunk) > 0 {
+		_, err := l.writeWithoutLock(chunk)
+		return err
+	}
+	return nil
+}
+
+func (l *defaultLogger) writeWithoutLock(p []byte) (int, error) {
+	n, err := l.doWrite(p)
+	l.stats.onWrite(n, err)
+	return n, err
+}
+
+func (l *defaultLogger) doWrite(p []byte) (int, error) {
+	if l.closed() {
+		return 0, ErrLoggerClosed
+	}
+	if err := l.checkWriteLen(len(p)); err != nil {
+		return 0, err
+	}
+	writeLen := int64(len(p))
 
 	if l.curWr == nil {
 		if err := l.openExistedOrNew(len(p)); err != nil {
@@ -50,12 +137,52 @@ func (l *defaultLogger) Write(p []byte) (int, error) {
 	}
 
 	n, err := l.curWr.Write(p)
-	l.curSize += int64(n)
+	atomic.AddInt64(&l.curSize, int64(n))
+	if err != nil {
+		return n, err
+	}
+	if l.curFile != nil {
+		l.curFile.addRecords(p)
+	}
+
+	switch l.c.GetDurability() {
+	case DurabilityWrite:
+		err = l.curWr.Sync()
+	case DurabilityInterval:
+		l.dirty = true
+	}
 
 	return n, err
 }
 
+// flush syncs current file if something was written since the last flush
+func (l *defaultLogger) flush() {
+	l.wrLock.Lock()
+	defer l.wrLock.Unlock()
+
+	if l.curWr == nil || !l.dirty {
+		return
+	}
+	l.dirty = false
+	if err := l.curWr.Sync(); err != nil {
+		l.stats.onWrite(0, err)
+	}
+}
+
+func (l *defaultLogger) checkWriteLen(writeLen int) error {
+	if int64(writeLen) > l.c.GetMaxSizeInBytes() {
+		return fmt.Errorf(
+			"write length %d exceeds maximum file size %d", writeLen, l.c.GetMaxSizeInBytes(),
+		)
+	}
+	return nil
+}
+
 func (l *defaultLogger) Close() error {
+	if l.batcher != nil {
+		l.batcher.close()
+	}
+
 	l.wrLock.Lock()
 	defer l.wrLock.Unlock()
 
@@ -70,6 +197,16 @@ func (l *defaultLogger) Close() error {
 	return nil
 }
 
+// closed must be checked under wrLock, since channels are closed by Close under the same lock
+func (l *defaultLogger) closed() bool {
+	select {
+	case <-l.closeChan:
+		return true
+	default:
+		return false
+	}
+}
+
 func (l *defaultLogger) Rotate() error {
 	l.wrLock.Lock()
 	defer l.wrLock.Unlock()
@@ -78,16 +215,22 @@ func (l *defaultLogger) Rotate() error {
 }
 
 func (l *defaultLogger) rotateWithoutLock() error {
-	select {
-	case l.rotateChan <- struct{}{}:
-	default:
-
+	if l.closed() {
+		return ErrLoggerClosed
 	}
+	// send must block, rotation goroutine may not be waiting for signal yet
+	l.rotateChan <- struct{}{}
 	return <-l.rotateErrChan
 }
 
 func (l *defaultLogger) prepare() {
-	if l.c.GetRotateTimeout() != 0 {
+	s, err := l.c.rotationSchedule()
+	if err != nil {
+		logger.Errorf(codes.JournalingError, "invalid rotation schedule, rotation timeout is used instead: %v", err)
+	}
+	if s != nil {
+		go l.rotateBySchedule(s)
+	} else if l.c.GetRotateTimeout() != 0 {
 		go func() {
 			for {
 				select {
@@ -100,35 +243,96 @@ func (l *defaultLogger) prepare() {
 		}()
 	}
 
+	if l.c.GetDurability() == DurabilityInterval {
+		go func() {
+			ticker := time.NewTicker(l.c.GetFlushInterval())
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					l.flush()
+				case <-l.closeChan:
+					return
+				}
+			}
+		}()
+	}
+
 	go l.awaitRotationSignal()
 }
 
+// rotateBySchedule rotates file at wall clock aligned times, which are not shifted by rotations on size limit
+func (l *defaultLogger) rotateBySchedule(s *schedule) {
+	next := s.nextRotation(time.Now())
+	for {
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+			_ = l.rotateIfNotEmpty()
+		case <-l.closeChan:
+			timer.Stop()
+			return
+		}
+		// wall clock may lag behind timer, the same time must not be scheduled twice
+		now := time.Now()
+		if now.Before(next) {
+			now = next
+		}
+		next = s.nextRotation(now)
+	}
+}
+
+// rotateIfNotEmpty doesn't produce empty files for windows without entries
+func (l *defaultLogger) rotateIfNotEmpty() error {
+	l.wrLock.Lock()
+	defer l.wrLock.Unlock()
+
+	if l.closed() || l.curWr == nil || l.curSize == 0 {
+		return nil
+	}
+	return l.rotateWithoutLock()
+}
+
 func (l *defaultLogger) awaitRotationSignal() {
 	for range l.rotateChan {
+		startedAt := time.Now()
 		if l.curWr != nil {
 			if err := l.curWr.Close(); err != nil {
 				l.rotateErrChan <- err
 				return
 			}
+			if l.curFile != nil {
+				// file without trailer is still readable, so error is ignored
+				_ = appendTrailer(l.c.GetFilename(), l.curFile.trailer())
+			}
 		}
 
-		if pipe, oldFile, err := openNewAndRenameExisted(l.c); err != nil {
+		if pipe, oldFile, err := openNewAndRenameExisted(l.c, l.newFileHeader()); err != nil {
 			l.rotateErrChan <- err
 		} else {
 			l.curWr = pipe
-			l.curSize = 0
-			if l.afterRotation != nil && oldFile != "" {
-				go func() {
-					if log, err := MakeLogFile(l.c, oldFile); err == nil {
-						l.afterRotation(*log)
-					}
-				}()
-			}
+			l.curFile = l.newFileStats()
+			l.dirty = false
+			atomic.StoreInt64(&l.curSize, 0)
+			l.stats.onRotation(time.Since(startedAt))
+			l.notifyRotation(oldFile)
 			l.rotateErrChan <- nil
+			// next rotation waits for retention, while writes are not blocked
+			removeExpiredLogs(l.c, l.afterRemoval)
 		}
 	}
 }
 
+func (l *defaultLogger) notifyRotation(oldFile string) {
+	if l.afterRotation != nil && oldFile != "" {
+		go func() {
+			if log, err := MakeLogFile(l.c, oldFile); err == nil {
+				l.afterRotation(*log)
+			}
+		}()
+	}
+}
+
 // openExistedOrNew opens the logfile if it exists and if the current write
 // would not put it over MaxSize.  If there is no such file or the write would
 // put it over the MaxSize, a new file is created.
@@ -136,11 +340,12 @@ func (l *defaultLogger) openExistedOrNew(writeLen int) error {
 	filename := l.c.GetFilename()
 	info, err := os.Stat(filename)
 	if os.IsNotExist(err) {
-		if p, _, err := openNewAndRenameExisted(l.c); err != nil {
+		if p, _, err := openNewAndRenameExisted(l.c, l.newFileHeader()); err != nil {
 			return err
 		} else {
 			l.curWr = p
-			l.curSize = 0
+			l.curFile = l.newFileStats()
+			atomic.StoreInt64(&l.curSize, 0)
 			return nil
 		}
 	}
@@ -152,20 +357,54 @@ func (l *defaultLogger) openExistedOrNew(writeLen int) error {
 		return l.rotateWithoutLock()
 	}
 
-	p, err := makePipe(l.c, filename, os.O_APPEND|os.O_WRONLY, 0644)
+	var curFile *fileStats
+	if l.c.FileHeader {
+		curFile, err = readFileStats(l.c, filename)
+		if err != nil || curFile == nil {
+			// trailer can't be written to corrupted file or file without header
+			return l.rotateWithoutLock()
+		}
+	}
+
+	p, err := makePipe(l.c, filename, os.O_APPEND|os.O_WRONLY, 0644, nil)
 	if err != nil {
 		// if we fail to open the old log file for some reason, just ignore
 		// it and open a new log file.
-		p, _, err = openNewAndRenameExisted(l.c)
-	}
-	if err != nil {
+		if p, _, err = openNewAndRenameExisted(l.c, l.newFileHeader()); err == nil {
+			l.curWr = p
+			l.curFile = l.newFileStats()
+			atomic.StoreInt64(&l.curSize, 0)
+		}
 		return err
 	}
 	l.curWr = p
-	l.curSize = info.Size()
+	l.curFile = curFile
+	atomic.StoreInt64(&l.curSize, info.Size())
 	return nil
 }
 
+// newFileHeader returns nil if file header is disabled
+func (l *defaultLogger) newFileHeader() *entry.FileHeader {
+	if !l.c.FileHeader {
+		return nil
+	}
+	return &entry.FileHeader{
+		Version:    entry.FileFormatVersion,
+		ModuleName: l.moduleName,
+		Host:       l.host,
+		Codec:      string(l.c.GetCodec()),
+		Encrypted:  l.c.IsEncrypted(),
+		CreatedAt:  entry.FormatTime(time.Now().UTC()),
+	}
+}
+
+func (l *defaultLogger) newFileStats() *fileStats {
+	if !l.c.FileHeader {
+		return nil
+	}
+	return newFileStats()
+}
+
 func NewDefaultLogger(config Config, opts ...Option) Logger {
 	l := &defaultLogger{
 		c:             config,
@@ -177,6 +416,19 @@ func NewDefaultLogger(config Config, opts ...Option) Logger {
 	for _, opt := range opts {
 		opt(l)
 	}
+	if config.Codec != "" {
+		if _, err := codec.Parse(config.Codec); err != nil {
+			logger.Errorf(codes.JournalingError, "invalid codec, compression setting is used instead: %v", err)
+		}
+	}
+
+	// on failure active file is appended or rotated by openExistedOrNew as before
+	_ = l.recoverActiveFile()
+	removeExpiredLogs(config, l.afterRemoval)
+
+	if config.IsBatched() {
+		l.batcher = newBatcher(config.GetBatchSize(), config.GetBatchTimeout(), l.writeBatch)
+	}
 
 	l.prepare()
 
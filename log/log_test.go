@@ -0,0 +1,381 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/integration-system/isp-journal/codec"
+	"github.com/integration-system/isp-journal/entry"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBatchedWriteKeepsMaxSize(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "journal")
+	a.NoError(err)
+	defer os.RemoveAll(dir)
+
+	l := NewDefaultLogger(Config{
+		Filename:  filepath.Join(dir, "test.log"),
+		MaxSizeMb: 1,
+		BatchSize: 8 * 1000,
+	})
+
+	record := bytes.Repeat([]byte{'a'}, 1000)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 300; j++ {
+				n, err := l.Write(record)
+				a.NoError(err)
+				a.Equal(len(record), n)
+			}
+		}()
+	}
+	wg.Wait()
+	a.NoError(l.Close())
+
+	files, err := ioutil.ReadDir(dir)
+	a.NoError(err)
+	a.True(len(files) > 2)
+	total := int64(0)
+	for _, f := range files {
+		a.True(f.Size() <= 1024*1024)
+		a.EqualValues(0, f.Size()%int64(len(record)))
+		total += f.Size()
+	}
+	a.EqualValues(8*300*len(record), total)
+}
+
+func TestRotateAfterClose(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "journal")
+	a.NoError(err)
+	defer os.RemoveAll(dir)
+
+	l := NewDefaultLogger(Config{
+		Filename:        filepath.Join(dir, "test.log"),
+		MaxSizeMb:       1,
+		RotateTimeoutMs: 1,
+	})
+	record := bytes.Repeat([]byte{'a'}, 600*1024)
+	_, err = l.Write(record)
+	a.NoError(err)
+	a.NoError(l.Close())
+
+	a.Equal(ErrLoggerClosed, l.Rotate())
+	_, err = l.Write(record)
+	a.Equal(ErrLoggerClosed, err)
+	// timer goroutine may race with close
+	time.Sleep(10 * time.Millisecond)
+}
+
+func TestCodec(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "journal")
+	a.NoError(err)
+	defer os.RemoveAll(dir)
+
+	cfg := Config{
+		Filename:         filepath.Join(dir, "test.log"),
+		MaxSizeMb:        1,
+		Compress:         true,
+		Codec:            "zstd",
+		CompressionLevel: 5,
+		BufferSize:       4096,
+	}
+	a.Equal(filepath.Join(dir, "test.log.zst"), cfg.GetFilename())
+
+	l := NewDefaultLogger(cfg)
+	record := bytes.Repeat([]byte{'a'}, 1000)
+	_, err = l.Write(record)
+	a.NoError(err)
+	a.NoError(l.Rotate())
+	a.NoError(l.Close())
+
+	logs, err := CollectExistedLogs(cfg)
+	a.NoError(err)
+	a.Len(logs, 1)
+	a.Equal(codec.Zstd, logs[0].Codec)
+	a.True(logs[0].Compressed)
+
+	f, err := os.Open(logs[0].FullPath)
+	a.NoError(err)
+	defer f.Close()
+	r, err := codec.NewReader(codec.Zstd, f)
+	a.NoError(err)
+	data, err := ioutil.ReadAll(r)
+	a.NoError(err)
+	a.Equal(record, data)
+
+	a.Equal(codec.Gzip, Config{Compress: true, Codec: "unknown"}.GetCodec())
+	a.Equal(codec.None, Config{Codec: "none", Compress: true}.GetCodec())
+}
+
+func TestDurability(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "journal")
+	a.NoError(err)
+	defer os.RemoveAll(dir)
+
+	record := bytes.Repeat([]byte{'a'}, 100)
+	readActive := func(cfg Config) []byte {
+		f, err := os.Open(cfg.GetFilename())
+		a.NoError(err)
+		defer f.Close()
+		r, err := codec.NewReader(codec.Gzip, f)
+		if err != nil {
+			return nil
+		}
+		// stream is not finished until rotation
+		data, _ := ioutil.ReadAll(r)
+		return data
+	}
+
+	for i, durability := range []string{DurabilityRotation, DurabilityWrite, DurabilityInterval} {
+		cfg := Config{
+			Filename:        filepath.Join(dir, fmt.Sprintf("test%d.log", i)),
+			MaxSizeMb:       1,
+			Compress:        true,
+			BufferSize:      4096,
+			Durability:      durability,
+			FlushIntervalMs: 10,
+		}
+		l := NewDefaultLogger(cfg)
+		_, err = l.Write(record)
+		a.NoError(err)
+
+		switch durability {
+		case DurabilityRotation:
+			a.Empty(readActive(cfg))
+		case DurabilityWrite:
+			a.Equal(record, readActive(cfg))
+		case DurabilityInterval:
+			time.Sleep(100 * time.Millisecond)
+			a.Equal(record, readActive(cfg))
+		}
+		a.NoError(l.Close())
+		a.Equal(record, readActive(cfg))
+	}
+}
+
+func TestRecoverActiveFile(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "journal")
+	a.NoError(err)
+	defer os.RemoveAll(dir)
+
+	cfg := Config{
+		Filename:   filepath.Join(dir, "test.log"),
+		MaxSizeMb:  1,
+		Compress:   true,
+		FileHeader: true,
+	}
+	// process was killed in the middle of writing the third record, gzip stream is not finished
+	data := bytes.Buffer{}
+	a.NoError(entry.WriteFileHeader(&data, &entry.FileHeader{ModuleName: "module"}))
+	w, err := codec.NewWriter(codec.Gzip, &data, 0)
+	a.NoError(err)
+	records := make([]byte, 0)
+	for i, level := range []entry.Level{entry.LevelInfo, entry.LevelError, entry.LevelInfo} {
+		record, err := entry.MarshalToBytes(&entry.Entry{Level: string(level), Time: entry.FormatTime(time.Now())})
+		a.NoError(err)
+		if i == 2 {
+			record = record[:len(record)/2]
+		} else {
+			records = append(records, record...)
+		}
+		_, err = w.Write(record)
+		a.NoError(err)
+	}
+	a.NoError(w.(interface{ Flush() error }).Flush())
+	a.NoError(ioutil.WriteFile(cfg.GetFilename(), data.Bytes(), 0644))
+
+	rotated := make(chan LogFile, 1)
+	l := NewDefaultLogger(cfg, WithAfterRotation(func(f LogFile) {
+		rotated <- f
+	}))
+	defer l.Close()
+
+	f := <-rotated
+	_, err = os.Stat(cfg.GetFilename())
+	a.True(os.IsNotExist(err))
+	file, err := os.Open(f.FullPath)
+	a.NoError(err)
+	defer file.Close()
+
+	info, err := file.Stat()
+	a.NoError(err)
+	header, trailer, err := entry.ReadFileSummary(file, info.Size())
+	a.NoError(err)
+	a.Equal("module", header.ModuleName)
+	a.Equal(map[string]int64{"OK": 1, "ERROR": 1}, trailer.Levels)
+
+	r, err := entry.NewFileReader(file, nil, codec.Unknown, codec.None)
+	a.NoError(err)
+	recovered, err := ioutil.ReadAll(r)
+	a.NoError(err)
+	a.Equal(records, recovered)
+}
+
+func TestRetention(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "journal")
+	a.NoError(err)
+	defer os.RemoveAll(dir)
+
+	now := time.Now().UTC()
+	ages := []time.Duration{10 * 24 * time.Hour, 3 * 24 * time.Hour, 2 * time.Hour, time.Hour, 0}
+	makeFiles := func(size int) []string {
+		names := make([]string, 0)
+		for _, age := range ages {
+			name := filepath.Join(dir, "test-"+now.Add(-age).Format(backupTimeFormat)+".log")
+			a.NoError(ioutil.WriteFile(name, make([]byte, size), 0644))
+			names = append(names, name)
+		}
+		return names
+	}
+	removeExpired := func(cfg Config) []string {
+		removed := make([]string, 0)
+		removeExpiredLogs(cfg, func(f LogFile) {
+			removed = append(removed, f.FullPath)
+		})
+		return removed
+	}
+	cfg := Config{Filename: filepath.Join(dir, "test.log")}
+
+	names := makeFiles(1024)
+	a.Empty(removeExpired(cfg))
+	cfg.MaxAgeDays = 5
+	a.Equal(names[:1], removeExpired(cfg))
+	cfg.MaxBackups = 3
+	a.Equal(names[1:2], removeExpired(cfg))
+	logs, err := CollectExistedLogs(cfg)
+	a.NoError(err)
+	a.Len(logs, 3)
+
+	names = makeFiles(400 * 1024)
+	cfg = Config{Filename: filepath.Join(dir, "test.log"), MaxTotalSizeMb: 1}
+	a.ElementsMatch(names[:3], removeExpired(cfg))
+
+	// the newest file is kept even if it alone exceeds total size
+	newest := filepath.Join(dir, "test-"+now.Add(time.Hour).Format(backupTimeFormat)+".log")
+	a.NoError(ioutil.WriteFile(newest, make([]byte, 2*1024*1024), 0644))
+	a.ElementsMatch(names[3:], removeExpired(cfg))
+	logs, err = CollectExistedLogs(cfg)
+	a.NoError(err)
+	a.Len(logs, 1)
+	a.Equal(newest, logs[0].FullPath)
+}
+
+func TestDiskGuard(t *testing.T) {
+	a := assert.New(t)
+
+	free := uint64(200 * 1024 * 1024)
+	lock := sync.Mutex{}
+	freeSpace := func(dir string) (uint64, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		return free, nil
+	}
+	setFree := func(mb uint64) {
+		lock.Lock()
+		free = mb * 1024 * 1024
+		lock.Unlock()
+	}
+	events := make(chan DiskSpaceEvent, 10)
+	cfg := Config{Filename: "/not/existed/dir/test.log", MinFreeSpaceMb: 100, DegradeMode: "stop", FreeSpaceCheckMs: 5}
+
+	a.Nil(newDiskGuard(Config{}, nil, freeSpace))
+	a.Equal(DegradeNone, (*DiskGuard)(nil).Mode())
+
+	g := newDiskGuard(cfg, func(e DiskSpaceEvent) {
+		events <- e
+	}, freeSpace)
+	defer g.Close()
+	a.Equal(DegradeNone, g.Mode())
+
+	setFree(50)
+	e := <-events
+	a.Equal(DiskSpaceEvent{Mode: DegradeStop, PrevMode: DegradeNone, FreeBytes: 50 * 1024 * 1024}, e)
+	a.Equal(DegradeStop, g.Mode())
+
+	// threshold must be exceeded by 10% to return to normal mode
+	setFree(105)
+	time.Sleep(50 * time.Millisecond)
+	a.Equal(DegradeStop, g.Mode())
+	setFree(120)
+	e = <-events
+	a.Equal(DegradeNone, e.Mode)
+	a.Equal(DegradeStop, e.PrevMode)
+	a.Equal(DegradeNone, g.Mode())
+	a.Len(events, 0)
+
+	a.Equal(DegradeDropNonError, Config{DegradeMode: "unknown"}.GetDegradeMode())
+}
+
+func TestRotationSchedule(t *testing.T) {
+	a := assert.New(t)
+
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	a.NoError(err)
+	now := time.Date(2020, 11, 1, 10, 7, 30, 0, time.UTC)
+
+	cases := []struct {
+		schedule string
+		timezone string
+		next     time.Time
+	}{
+		{"15m", "", time.Date(2020, 11, 1, 10, 15, 0, 0, time.UTC)},
+		{"hourly", "", time.Date(2020, 11, 1, 11, 0, 0, 0, time.UTC)},
+		{"daily", "", time.Date(2020, 11, 2, 0, 0, 0, 0, time.UTC)},
+		{"daily 10:30", "", time.Date(2020, 11, 1, 10, 30, 0, 0, time.UTC)},
+		{"Daily 09:00", "", time.Date(2020, 11, 2, 9, 0, 0, 0, time.UTC)},
+		{"daily 14:00", "Europe/Moscow", time.Date(2020, 11, 1, 14, 0, 0, 0, moscow)},
+		{"daily 13:00", "Europe/Moscow", time.Date(2020, 11, 2, 13, 0, 0, 0, moscow)},
+		// the last window of the day is shorter if period doesn't divide the day
+		{"7h", "", time.Date(2020, 11, 1, 14, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		s, err := parseSchedule(c.schedule, c.timezone)
+		a.NoError(err, c.schedule)
+		a.True(c.next.Equal(s.nextRotation(now)), "%s: %v", c.schedule, s.nextRotation(now))
+	}
+
+	s, err := parseSchedule("7h", "")
+	a.NoError(err)
+	a.True(time.Date(2020, 11, 2, 0, 0, 0, 0, time.UTC).Equal(s.nextRotation(time.Date(2020, 11, 1, 22, 0, 0, 0, time.UTC))))
+	// boundary itself is not scheduled again
+	s, err = parseSchedule("15m", "")
+	a.NoError(err)
+	a.True(time.Date(2020, 11, 1, 10, 30, 0, 0, time.UTC).Equal(s.nextRotation(time.Date(2020, 11, 1, 10, 15, 0, 0, time.UTC))))
+
+	for _, invalid := range []string{"10s", "weekly", "daily 25:00", "-5m"} {
+		_, err := parseSchedule(invalid, "")
+		a.Error(err, invalid)
+	}
+	_, err = parseSchedule("hourly", "Mars/Olympus")
+	a.Error(err)
+	s, err = Config{RotationSchedule: "weekly"}.rotationSchedule()
+	a.Error(err)
+	a.Nil(s)
+	s, err = Config{RotationSchedule: "daily", RotationTimezone: "Mars/Olympus"}.rotationSchedule()
+	a.Error(err)
+	a.Nil(s)
+	s, err = Config{}.rotationSchedule()
+	a.NoError(err)
+	a.Nil(s)
+}
@@ -7,3 +7,18 @@ func WithAfterRotation(callback func(prevFile LogFile)) Option {
 		l.afterRotation = callback
 	}
 }
+
+// WithAfterRemoval sets callback called for every rotated file removed by retention policy
+func WithAfterRemoval(callback func(removed LogFile)) Option {
+	return func(l *defaultLogger) {
+		l.afterRemoval = callback
+	}
+}
+
+// WithModuleInfo sets module name and host written to file header
+func WithModuleInfo(moduleName, host string) Option {
+	return func(l *defaultLogger) {
+		l.moduleName = moduleName
+		l.host = host
+	}
+}
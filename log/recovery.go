@@ -0,0 +1,169 @@
+package log
+
+import (
+	"github.com/integration-system/isp-journal/codec"
+	"github.com/integration-system/isp-journal/entry"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	recoveredSuffix = ".recovered"
+)
+
+// recoverActiveFile finalizes active file left by previous process and moves it out of the way like rotation does,
+// so new entries are never appended to unfinished compressed or encrypted stream
+func (l *defaultLogger) recoverActiveFile() error {
+	filename := l.c.GetFilename()
+	info, err := os.Stat(filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		return os.Remove(filename)
+	}
+
+	startedAt := time.Now()
+	if empty, err := finalizeFile(l.c, filename, l.newFileHeader()); err != nil {
+		return err
+	} else if empty {
+		return os.Remove(filename)
+	}
+	newname := getBackupFileName(filename)
+	if err := os.Rename(filename, newname); err != nil {
+		return err
+	}
+	l.stats.onRotation(time.Since(startedAt))
+	l.notifyRotation(newname)
+	return nil
+}
+
+// finalizeFile validates file and rewrites complete records of damaged file with current config,
+// trailer is written if file has header. File which can't be decoded at all is left as is.
+// Returns true if file is valid but has no entries.
+func finalizeFile(c Config, filename string, header *entry.FileHeader) (bool, error) {
+	stats, fileHeader, damaged, err := scanFile(c, filename)
+	if err != nil || stats == nil {
+		return false, nil
+	}
+	if !damaged {
+		if stats.entries == 0 {
+			return true, nil
+		}
+		if fileHeader == nil {
+			return false, nil
+		}
+		return false, appendTrailer(filename, stats.trailer())
+	}
+	if stats.entries == 0 {
+		// nothing to recover, data is kept for manual inspection
+		return false, nil
+	}
+
+	if fileHeader != nil {
+		header = fileHeader
+	}
+	tmpName := filename + recoveredSuffix
+	if err := rewriteFile(c, filename, tmpName, header); err != nil {
+		_ = os.Remove(tmpName)
+		return false, err
+	}
+	return false, os.Rename(tmpName, filename)
+}
+
+// scanFile returns nil stats if file is already finalized with trailer
+func scanFile(c Config, filename string) (*fileStats, *entry.FileHeader, bool, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if header, trailer, err := entry.ReadFileSummary(f, info.Size()); err != nil {
+		return nil, nil, false, err
+	} else if trailer != nil {
+		return nil, header, false, nil
+	}
+
+	damaged := false
+	stats := newFileStats()
+	header, err := readRecords(c, f, func(record []byte) error {
+		stats.addRecord(record)
+		return nil
+	}, func(entry.SkippedRange) {
+		damaged = true
+	})
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return stats, header, damaged, nil
+}
+
+func rewriteFile(c Config, src, dst string, header *entry.FileHeader) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	p, err := makePipe(c, dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode(), header)
+	if err != nil {
+		return err
+	}
+	stats := newFileStats()
+	_, err = readRecords(c, f, func(record []byte) error {
+		stats.addRecord(record)
+		_, err := p.Write(entry.FrameRecord(record, c.RecordChecksum))
+		return err
+	}, nil)
+	if err != nil {
+		_ = p.Close()
+		return err
+	}
+	if err := p.Close(); err != nil {
+		return err
+	}
+	if header == nil {
+		return nil
+	}
+	return appendTrailer(dst, stats.trailer())
+}
+
+// readRecords reads all valid records of file skipping damaged data
+func readRecords(c Config, f *os.File, handler func(record []byte) error, onSkip func(entry.SkippedRange)) (*entry.FileHeader, error) {
+	var keyProvider func() ([]byte, error)
+	if c.IsEncrypted() {
+		keyProvider = c.GetEncryptionKey
+	}
+	r, err := entry.NewFileReader(f, keyProvider, c.GetCodec(), codec.None)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	s := entry.NewRecordScanner(r, onSkip)
+	for {
+		record, err := s.Next()
+		if err == io.EOF {
+			return r.Header(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := handler(record); err != nil {
+			return nil, err
+		}
+	}
+}
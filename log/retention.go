@@ -0,0 +1,44 @@
+package log
+
+import (
+	"os"
+	"sort"
+	"time"
+)
+
+// removeExpiredLogs removes the oldest rotated files which exceed retention limits of config,
+// the newest rotated file is always kept, since it may be still transferred
+func removeExpiredLogs(c Config, afterRemoval func(removed LogFile)) {
+	if !c.HasRetention() {
+		return
+	}
+	logs, err := CollectExistedLogs(c)
+	if err != nil {
+		return
+	}
+	sort.Slice(logs, func(i, j int) bool {
+		return logs[i].CreatedAt.After(logs[j].CreatedAt)
+	})
+
+	minCreatedAt := time.Time{}
+	if c.GetMaxAge() > 0 {
+		minCreatedAt = time.Now().UTC().Add(-c.GetMaxAge())
+	}
+	totalSize := int64(0)
+	for i, f := range logs {
+		totalSize += f.Size()
+		if i == 0 {
+			continue
+		}
+		expired := (c.MaxBackups > 0 && i >= c.MaxBackups) ||
+			f.CreatedAt.Before(minCreatedAt) ||
+			(c.GetMaxTotalSizeInBytes() > 0 && totalSize > c.GetMaxTotalSizeInBytes())
+		if !expired {
+			continue
+		}
+		// file may be already removed after transfer
+		if err := os.Remove(f.FullPath); err == nil && afterRemoval != nil {
+			afterRemoval(f)
+		}
+	}
+}
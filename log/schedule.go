@@ -0,0 +1,77 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	scheduleHourly = "hourly"
+	scheduleDaily  = "daily"
+)
+
+// schedule of rotation aligned to wall clock, either every N from midnight or daily at specified time
+type schedule struct {
+	every time.Duration
+	daily bool
+	hour  int
+	min   int
+	loc   *time.Location
+}
+
+// parseSchedule accepts 'hourly', 'daily', 'daily HH:MM' or duration like '15m' at least one minute long,
+// durations are counted from midnight, so the last window of the day may be shorter
+func parseSchedule(s string, timezone string) (*schedule, error) {
+	loc := time.UTC
+	if timezone != "" {
+		l, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rotation timezone '%s': %v", timezone, err)
+		}
+		loc = l
+	}
+
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch {
+	case s == scheduleHourly:
+		return &schedule{every: time.Hour, loc: loc}, nil
+	case s == scheduleDaily:
+		return &schedule{daily: true, loc: loc}, nil
+	case strings.HasPrefix(s, scheduleDaily+" "):
+		at, err := time.Parse("15:04", strings.TrimSpace(s[len(scheduleDaily):]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid rotation schedule '%s': %v", s, err)
+		}
+		return &schedule{daily: true, hour: at.Hour(), min: at.Minute(), loc: loc}, nil
+	}
+
+	every, err := time.ParseDuration(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rotation schedule '%s': %v", s, err)
+	}
+	if every < time.Minute {
+		return nil, fmt.Errorf("invalid rotation schedule '%s': period must be at least one minute", s)
+	}
+	return &schedule{every: every, loc: loc}, nil
+}
+
+// nextRotation returns the first scheduled time after now
+func (s *schedule) nextRotation(now time.Time) time.Time {
+	t := now.In(s.loc)
+	if s.daily {
+		next := time.Date(t.Year(), t.Month(), t.Day(), s.hour, s.min, 0, 0, s.loc)
+		if !next.After(t) {
+			next = time.Date(t.Year(), t.Month(), t.Day()+1, s.hour, s.min, 0, 0, s.loc)
+		}
+		return next
+	}
+
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, s.loc)
+	nextMidnight := time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.loc)
+	next := midnight.Add((t.Sub(midnight)/s.every + 1) * s.every)
+	if next.After(nextMidnight) {
+		return nextMidnight
+	}
+	return next
+}
@@ -0,0 +1,45 @@
+package log
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats contains counters of logger since it was created
+type Stats struct {
+	BytesWritten     int64
+	WriteErrors      int64
+	Rotations        int64
+	RotationDuration time.Duration
+	// CurrentFileWritten is count of bytes written to current file before compression and encryption
+	CurrentFileWritten int64
+}
+
+type loggerStats struct {
+	bytesWritten     int64
+	writeErrors      int64
+	rotations        int64
+	rotationDuration int64
+}
+
+func (s *loggerStats) onWrite(n int, err error) {
+	atomic.AddInt64(&s.bytesWritten, int64(n))
+	if err != nil {
+		atomic.AddInt64(&s.writeErrors, 1)
+	}
+}
+
+func (s *loggerStats) onRotation(duration time.Duration) {
+	atomic.AddInt64(&s.rotations, 1)
+	atomic.AddInt64(&s.rotationDuration, int64(duration))
+}
+
+func (l *defaultLogger) Stats() Stats {
+	return Stats{
+		BytesWritten:       atomic.LoadInt64(&l.stats.bytesWritten),
+		WriteErrors:        atomic.LoadInt64(&l.stats.writeErrors),
+		Rotations:          atomic.LoadInt64(&l.stats.rotations),
+		RotationDuration:   time.Duration(atomic.LoadInt64(&l.stats.rotationDuration)),
+		CurrentFileWritten: atomic.LoadInt64(&l.curSize),
+	}
+}
@@ -0,0 +1,244 @@
+package logging
+
+import (
+	"context"
+	"github.com/golang/protobuf/proto"
+	journal "github.com/integration-system/isp-journal"
+	"github.com/integration-system/isp-journal/codes"
+	"github.com/integration-system/isp-journal/entry"
+	log "github.com/integration-system/isp-log"
+	"google.golang.org/grpc"
+	grpcCodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	GrpcCodeLabel      = "grpc.code"
+	GrpcRequestsLabel  = "grpc.requests"
+	GrpcResponsesLabel = "grpc.responses"
+)
+
+type GrpcOption func(g *grpcLogging)
+
+// WithMetadataKeys copies values of specified metadata keys to entry labels
+func WithMetadataKeys(keys ...string) GrpcOption {
+	return func(g *grpcLogging) {
+		g.metadataKeys = keys
+	}
+}
+
+// WithStreamSummary writes single entry per stream with count of messages
+// instead of entry per each message
+func WithStreamSummary() GrpcOption {
+	return func(g *grpcLogging) {
+		g.streamSummary = true
+	}
+}
+
+// LevelByCode maps grpc status code to entry level, client side errors are logged as WARN
+func LevelByCode(code grpcCodes.Code) entry.Level {
+	switch code {
+	case grpcCodes.OK:
+		return entry.LevelInfo
+	case grpcCodes.Canceled, grpcCodes.InvalidArgument, grpcCodes.NotFound, grpcCodes.AlreadyExists,
+		grpcCodes.PermissionDenied, grpcCodes.FailedPrecondition, grpcCodes.Aborted,
+		grpcCodes.OutOfRange, grpcCodes.Unauthenticated:
+		return entry.LevelWarn
+	default:
+		return entry.LevelError
+	}
+}
+
+type grpcLogging struct {
+	journal       journal.Journal
+	metadataKeys  []string
+	streamSummary bool
+}
+
+func (g *grpcLogging) log(ctx context.Context, md metadata.MD, method string, req, res []byte, err error, duration time.Duration, labels map[string]string) {
+	code := status.Code(err)
+	ctx = metadata.NewIncomingContext(ctx, md)
+	if duration > 0 {
+		ctx = journal.WithDuration(ctx, duration)
+	}
+	mdLabels := make(map[string]string, len(g.metadataKeys)+len(labels)+1)
+	for _, key := range g.metadataKeys {
+		if values := md.Get(key); len(values) > 0 {
+			mdLabels[key] = values[0]
+		}
+	}
+	for key, value := range labels {
+		mdLabels[key] = value
+	}
+	mdLabels[GrpcCodeLabel] = code.String()
+	ctx = journal.WithLabels(ctx, mdLabels)
+
+	if err := g.journal.LogCtx(ctx, LevelByCode(code), method, req, res, err); err != nil {
+		log.Warnf(codes.JournalingError, "could not write to file journal: %v", err)
+	}
+}
+
+func UnaryServerInterceptor(j journal.Journal, opts ...GrpcOption) grpc.UnaryServerInterceptor {
+	g := newGrpcLogging(j, opts)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		startedAt := time.Now()
+		res, err := handler(ctx, req)
+		md, _ := metadata.FromIncomingContext(ctx)
+		g.log(ctx, md, info.FullMethod, marshal(req), marshal(res), err, time.Since(startedAt), nil)
+		return res, err
+	}
+}
+
+func UnaryClientInterceptor(j journal.Journal, opts ...GrpcOption) grpc.UnaryClientInterceptor {
+	g := newGrpcLogging(j, opts)
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		startedAt := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		md, _ := metadata.FromOutgoingContext(ctx)
+		var res []byte
+		if err == nil {
+			res = marshal(reply)
+		}
+		g.log(ctx, md, method, marshal(req), res, err, time.Since(startedAt), nil)
+		return err
+	}
+}
+
+func StreamServerInterceptor(j journal.Journal, opts ...GrpcOption) grpc.StreamServerInterceptor {
+	g := newGrpcLogging(j, opts)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		md, _ := metadata.FromIncomingContext(ss.Context())
+		s := &loggedStream{grpc: g, ctx: ss.Context(), md: md, method: info.FullMethod, startedAt: time.Now()}
+		err := handler(srv, &loggedServerStream{ServerStream: ss, stream: s})
+		s.finish(err)
+		return err
+	}
+}
+
+func StreamClientInterceptor(j journal.Journal, opts ...GrpcOption) grpc.StreamClientInterceptor {
+	g := newGrpcLogging(j, opts)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		s := &loggedStream{grpc: g, ctx: ctx, md: md, method: method, startedAt: time.Now()}
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			s.finish(err)
+			return nil, err
+		}
+		return &loggedClientStream{ClientStream: cs, stream: s}, nil
+	}
+}
+
+type loggedStream struct {
+	grpc      *grpcLogging
+	ctx       context.Context
+	md        metadata.MD
+	method    string
+	startedAt time.Time
+	requests  int64
+	responses int64
+	finished  int32
+}
+
+func (s *loggedStream) onRequest(msg interface{}) {
+	atomic.AddInt64(&s.requests, 1)
+	if !s.grpc.streamSummary {
+		s.grpc.log(s.ctx, s.md, s.method, marshal(msg), nil, nil, 0, nil)
+	}
+}
+
+func (s *loggedStream) onResponse(msg interface{}) {
+	atomic.AddInt64(&s.responses, 1)
+	if !s.grpc.streamSummary {
+		s.grpc.log(s.ctx, s.md, s.method, nil, marshal(msg), nil, 0, nil)
+	}
+}
+
+// finish writes summary entry or, in per message mode, entry with stream error
+func (s *loggedStream) finish(err error) {
+	if !atomic.CompareAndSwapInt32(&s.finished, 0, 1) {
+		return
+	}
+	if !s.grpc.streamSummary && err == nil {
+		return
+	}
+	labels := map[string]string{
+		GrpcRequestsLabel:  strconv.FormatInt(atomic.LoadInt64(&s.requests), 10),
+		GrpcResponsesLabel: strconv.FormatInt(atomic.LoadInt64(&s.responses), 10),
+	}
+	s.grpc.log(s.ctx, s.md, s.method, nil, nil, err, time.Since(s.startedAt), labels)
+}
+
+type loggedServerStream struct {
+	grpc.ServerStream
+	stream *loggedStream
+}
+
+func (s *loggedServerStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.stream.onResponse(m)
+	}
+	return err
+}
+
+func (s *loggedServerStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.stream.onRequest(m)
+	}
+	return err
+}
+
+// loggedClientStream journals stream when RecvMsg returns io.EOF or error,
+// so stream must be read till the end to be journaled
+type loggedClientStream struct {
+	grpc.ClientStream
+	stream *loggedStream
+}
+
+func (s *loggedClientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.stream.onRequest(m)
+	}
+	return err
+}
+
+func (s *loggedClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == nil:
+		s.stream.onResponse(m)
+	case err == io.EOF:
+		s.stream.finish(nil)
+	default:
+		s.stream.finish(err)
+	}
+	return err
+}
+
+func marshal(msg interface{}) []byte {
+	m, ok := msg.(proto.Message)
+	if !ok || m == nil {
+		return nil
+	}
+	bytes, err := proto.Marshal(m)
+	if err != nil {
+		return nil
+	}
+	return bytes
+}
+
+func newGrpcLogging(j journal.Journal, opts []GrpcOption) *grpcLogging {
+	g := &grpcLogging{journal: j}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
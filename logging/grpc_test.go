@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"context"
+	journal "github.com/integration-system/isp-journal"
+	"github.com/integration-system/isp-journal/entry"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"testing"
+)
+
+func TestGrpcInterceptors(t *testing.T) {
+	a := assert.New(t)
+
+	serverJournal := journal.NewMemoryJournal("server", "host", 0)
+	clientJournal := journal.NewMemoryJournal("client", "host", 0)
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(serverJournal, WithMetadataKeys("tenant"))),
+		grpc.StreamInterceptor(StreamServerInterceptor(serverJournal, WithStreamSummary())),
+	)
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("watched", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(server, healthServer)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(clientJournal)),
+		grpc.WithStreamInterceptor(StreamClientInterceptor(clientJournal)),
+	)
+	a.NoError(err)
+	defer conn.Close()
+	client := grpc_health_v1.NewHealthClient(conn)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "tenant", "1", "x-request-id", "42")
+	_, err = client.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	a.NoError(err)
+	_, err = client.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: "unknown"})
+	a.Error(err)
+
+	e := serverJournal.Entries()[0]
+	a.Equal("/grpc.health.v1.Health/Check", e.Event)
+	a.EqualValues(entry.LevelInfo, e.Level)
+	a.Equal("42", e.RequestId)
+	a.Equal(map[string]string{"tenant": "1", GrpcCodeLabel: "OK"}, e.Labels)
+	a.NotEmpty(e.Response)
+
+	e = clientJournal.Entries()[1]
+	a.EqualValues(entry.LevelWarn, e.Level)
+	a.Equal("NotFound", e.Labels[GrpcCodeLabel])
+	a.Equal("42", e.RequestId)
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	stream, err := client.Watch(watchCtx, &grpc_health_v1.HealthCheckRequest{Service: "watched"})
+	a.NoError(err)
+	_, err = stream.Recv()
+	a.NoError(err)
+	healthServer.Shutdown()
+	_, err = stream.Recv()
+	a.NoError(err)
+	cancel()
+	_, err = stream.Recv()
+	a.Error(err)
+
+	clientEntries := clientJournal.Entries()
+	a.Len(clientEntries, 6)
+	a.NotEmpty(clientEntries[2].Request)
+	a.NotEmpty(clientEntries[3].Response)
+	a.EqualValues(entry.LevelWarn, clientEntries[5].Level)
+	a.Equal("Canceled", clientEntries[5].Labels[GrpcCodeLabel])
+}
@@ -0,0 +1,210 @@
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	journal "github.com/integration-system/isp-journal"
+	"github.com/integration-system/isp-journal/codes"
+	"github.com/integration-system/isp-journal/entry"
+	log "github.com/integration-system/isp-log"
+	"google.golang.org/grpc/metadata"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultMaxBodySize = 64 * 1024
+
+	HttpMethodLabel = "http.method"
+	HttpStatusLabel = "http.status"
+)
+
+var (
+	tracingHeaders = []string{
+		journal.TraceIdMetadataKey,
+		journal.SpanIdMetadataKey,
+		journal.RequestIdMetadataKey,
+		journal.TraceParentMetadataKey,
+	}
+)
+
+type HttpOption func(m *httpMiddleware)
+
+// WithEventName sets function which makes event name from request, by default url path is used.
+// Routes with parameters in path (e.g. /users/{id}) must set it to route pattern,
+// otherwise every distinct url becomes separate event
+func WithEventName(eventName func(r *http.Request) string) HttpOption {
+	return func(m *httpMiddleware) {
+		m.eventName = eventName
+	}
+}
+
+// WithMaxBodySize limits captured request and response body size, by default 64Kb
+func WithMaxBodySize(size int) HttpOption {
+	return func(m *httpMiddleware) {
+		m.maxBodySize = size
+	}
+}
+
+type httpMiddleware struct {
+	journal     journal.Journal
+	next        http.Handler
+	eventName   func(r *http.Request) string
+	maxBodySize int
+}
+
+func (m *httpMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	startedAt := time.Now()
+
+	var reqBody []byte
+	// one more byte is read to find out whether body is cut
+	rest := &countingReader{}
+	if r.Body != nil {
+		body := r.Body
+		reqBody, _ = ioutil.ReadAll(io.LimitReader(body, int64(m.maxBodySize)+1))
+		rest.r = body
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(reqBody), rest), body}
+	}
+
+	rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK, limit: m.maxBodySize}
+	m.next.ServeHTTP(rec, r)
+
+	// length of body which was not read by handler is known from header only
+	reqLen := int64(len(reqBody)) + rest.n
+	if r.ContentLength > reqLen {
+		reqLen = r.ContentLength
+	}
+	if len(reqBody) > m.maxBodySize {
+		reqBody = reqBody[:m.maxBodySize]
+	}
+
+	ctx := r.Context()
+	md := metadata.MD{}
+	for _, header := range tracingHeaders {
+		if value := r.Header.Get(header); value != "" {
+			md.Set(header, value)
+		}
+	}
+	if incoming, ok := metadata.FromIncomingContext(ctx); ok {
+		md = metadata.Join(incoming, md)
+	}
+	ctx = metadata.NewIncomingContext(ctx, md)
+	ctx = journal.WithDuration(ctx, time.Since(startedAt))
+	ctx = journal.WithPayloadLengths(ctx, int(reqLen), rec.written)
+	ctx = journal.WithLabels(ctx, map[string]string{
+		HttpMethodLabel: r.Method,
+		HttpStatusLabel: strconv.Itoa(rec.status),
+	})
+
+	level := entry.LevelInfo
+	var err error
+	switch {
+	case rec.status >= http.StatusInternalServerError:
+		level = entry.LevelError
+	case rec.status >= http.StatusBadRequest:
+		level = entry.LevelWarn
+	}
+	if level != entry.LevelInfo {
+		err = errors.New(strconv.Itoa(rec.status) + " " + http.StatusText(rec.status))
+	}
+
+	if err := m.journal.LogCtx(ctx, level, m.eventName(r), reqBody, rec.body.Bytes(), err); err != nil {
+		log.Warnf(codes.JournalingError, "could not write to file journal: %v", err)
+	}
+}
+
+// HttpMiddleware journals every request handled by next handler,
+// level is derived from response status code: 5xx - ERROR, 4xx - WARN, otherwise OK
+func HttpMiddleware(j journal.Journal, opts ...HttpOption) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		m := &httpMiddleware{
+			journal:     j,
+			next:        next,
+			maxBodySize: defaultMaxBodySize,
+			eventName: func(r *http.Request) string {
+				return r.URL.Path
+			},
+		}
+		for _, opt := range opts {
+			opt(m)
+		}
+		return m
+	}
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	limit       int
+	// written is full length of response
+	written int
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	if free := r.limit - r.body.Len(); free > 0 {
+		if len(p) < free {
+			free = len(p)
+		}
+		r.body.Write(p[:free])
+	}
+	n, err := r.ResponseWriter.Write(p)
+	r.written += n
+	return n, err
+}
+
+func (r *responseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack takes over connection for protocol upgrades, journaled status is 101 if handler did not write header
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil && !r.wroteHeader {
+		r.status = http.StatusSwitchingProtocols
+		r.wroteHeader = true
+	}
+	return conn, rw, err
+}
+
+func (r *responseRecorder) Push(target string, opts *http.PushOptions) error {
+	if p, ok := r.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
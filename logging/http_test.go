@@ -0,0 +1,126 @@
+package logging
+
+import (
+	"bufio"
+	journal "github.com/integration-system/isp-journal"
+	"github.com/integration-system/isp-journal/entry"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHttpMiddleware(t *testing.T) {
+	a := assert.New(t)
+
+	j := journal.NewMemoryJournal("module", "host", 0)
+	handler := HttpMiddleware(j, WithMaxBodySize(4))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		a.NoError(err)
+		a.Equal("request body", string(body))
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		_, _ = w.Write([]byte("response"))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/ok", strings.NewReader("request body"))
+	req.Header.Set("X-Request-Id", "42")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	a.Equal("response", rec.Body.String())
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", strings.NewReader("request body")))
+	// length of body is unknown until it is read
+	req = httptest.NewRequest(http.MethodPost, "/chunked", strings.NewReader("request body"))
+	req.ContentLength = -1
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := j.Entries()
+	a.Len(entries, 3)
+
+	e := entries[0]
+	a.Equal("/ok", e.Event)
+	a.EqualValues(entry.LevelInfo, e.Level)
+	a.Equal("requ", string(e.Request))
+	a.Equal("resp", string(e.Response))
+	a.True(e.Truncated)
+	a.EqualValues(12, e.RequestLength)
+	a.EqualValues(8, e.ResponseLength)
+	a.Equal("42", e.RequestId)
+	a.Equal(map[string]string{HttpMethodLabel: "POST", HttpStatusLabel: "200"}, e.Labels)
+	a.True(e.Duration > 0)
+
+	e = entries[1]
+	a.Equal("/fail", e.Event)
+	a.EqualValues(entry.LevelError, e.Level)
+	a.Equal("502 Bad Gateway", e.ErrorText)
+
+	e = entries[2]
+	a.Equal("requ", string(e.Request))
+	a.True(e.Truncated)
+	a.EqualValues(12, e.RequestLength)
+	a.EqualValues(8, e.ResponseLength)
+}
+
+func TestHttpMiddlewareNotTruncated(t *testing.T) {
+	a := assert.New(t)
+
+	j := journal.NewMemoryJournal("module", "host", 0)
+	handler := HttpMiddleware(j, WithMaxBodySize(16))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("response"))
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/ok", strings.NewReader("request body")))
+
+	entries := j.Entries()
+	a.Len(entries, 1)
+	e := entries[0]
+	a.Equal("request body", string(e.Request))
+	a.Equal("response", string(e.Response))
+	a.False(e.Truncated)
+	a.Zero(e.RequestLength)
+	a.Zero(e.ResponseLength)
+}
+
+func TestHttpMiddlewareHijack(t *testing.T) {
+	a := assert.New(t)
+
+	j := journal.NewMemoryJournal("module", "host", 0)
+	done := make(chan struct{})
+	middleware := HttpMiddleware(j)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, ok := w.(http.Hijacker)
+		if !a.True(ok) {
+			return
+		}
+		conn, rw, err := h.Hijack()
+		if !a.NoError(err) {
+			return
+		}
+		defer conn.Close()
+		_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: test\r\nConnection: Upgrade\r\n\r\n")
+		_ = rw.Flush()
+	}))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		middleware.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	a.NoError(err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET /ws HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n"))
+	a.NoError(err)
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	a.NoError(err)
+	a.Equal("HTTP/1.1 101 Switching Protocols\r\n", line)
+
+	<-done
+	entries := j.Entries()
+	a.Len(entries, 1)
+	a.Equal("/ws", entries[0].Event)
+	a.Equal("101", entries[0].Labels[HttpStatusLabel])
+}
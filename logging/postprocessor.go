@@ -1,34 +1,75 @@
 package logging
 
 import (
+	"context"
 	journal "github.com/integration-system/isp-journal"
 	"github.com/integration-system/isp-journal/codes"
 	"github.com/integration-system/isp-lib/v2/backend"
 	log "github.com/integration-system/isp-log"
+	"google.golang.org/grpc/metadata"
+	"sync"
+	"time"
 )
 
-func WithLogging(journal journal.Journal, enable bool, includeMethods ...string) backend.PostProcessor {
+var (
+	durations = sync.Map{}
+)
+
+// WithDuration wraps module interceptor (may be nil) and measures handler duration,
+// so WithLogging can write it to journal.
+// Must be installed together with WithLogging post processor, which takes measured duration
+// out of the shared map; without WithLogging durations are never released
+func WithDuration(interceptor backend.Interceptor) backend.Interceptor {
+	return func(ctx backend.RequestCtx, proceed func() (interface{}, error)) (interface{}, error) {
+		startedAt := time.Now()
+		defer func() {
+			durations.Store(ctx, time.Since(startedAt))
+		}()
+		if interceptor != nil {
+			return interceptor(ctx, proceed)
+		}
+		return proceed()
+	}
+}
+
+// WithLogging writes handled requests of included methods to journal.
+// Request duration is recorded only if module interceptor is wrapped with WithDuration
+func WithLogging(j journal.Journal, enable bool, includeMethods ...string) backend.PostProcessor {
 	includedMethods := make(map[string]bool, len(includeMethods))
 	for _, m := range includeMethods {
 		includedMethods[m] = true
 	}
 	return func(ctx backend.RequestCtx) {
+		duration := popDuration(ctx)
 		if !enable {
 			return
 		}
 
 		method := ctx.Method()
 		if include := includedMethods[method]; include {
+			logCtx := metadata.NewIncomingContext(context.Background(), ctx.Metadata())
+			if duration > 0 {
+				logCtx = journal.WithDuration(logCtx, duration)
+			}
 			err := ctx.Error()
 			if err != nil {
-				if err := journal.Error(method, ctx.RequestBody(), ctx.ResponseBody(), err); err != nil {
+				if err := j.ErrorCtx(logCtx, method, ctx.RequestBody(), ctx.ResponseBody(), err); err != nil {
 					log.Warnf(codes.JournalingError, "could not write to file journal: %v", err)
 				}
 			} else {
-				if err := journal.Info(method, ctx.RequestBody(), ctx.ResponseBody()); err != nil {
+				if err := j.InfoCtx(logCtx, method, ctx.RequestBody(), ctx.ResponseBody()); err != nil {
 					log.Warnf(codes.JournalingError, "could not write to file journal: %v", err)
 				}
 			}
 		}
 	}
 }
+
+func popDuration(ctx backend.RequestCtx) time.Duration {
+	duration, ok := durations.Load(ctx)
+	if !ok {
+		return 0
+	}
+	durations.Delete(ctx)
+	return duration.(time.Duration)
+}
@@ -0,0 +1,162 @@
+package journal
+
+import (
+	"context"
+	"github.com/integration-system/isp-journal/entry"
+	"github.com/integration-system/isp-journal/search"
+	"sync"
+)
+
+// MemoryJournal keeps entries in memory, it is intended for unit tests.
+// If capacity is positive only the last capacity entries are kept.
+type MemoryJournal struct {
+	moduleName string
+	host       string
+	capacity   int
+
+	lock    sync.RWMutex
+	entries []*entry.Entry
+	// start is index of the oldest entry when ring buffer is full
+	start  int
+	seq    uint64
+	closed bool
+}
+
+func (j *MemoryJournal) Log(level entry.Level, event string, req []byte, res []byte, err error) error {
+	return j.LogCtx(context.Background(), level, event, req, res, err)
+}
+
+func (j *MemoryJournal) LogCtx(ctx context.Context, level entry.Level, event string, req []byte, res []byte, err error) error {
+	e := newEntry(ctx, j.moduleName, j.host, level, event, req, res, err)
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if j.closed {
+		return ErrJournalClosed
+	}
+	j.seq++
+	e.Seq = j.seq
+	if j.capacity > 0 && len(j.entries) == j.capacity {
+		j.entries[j.start] = e
+		j.start = (j.start + 1) % j.capacity
+	} else {
+		j.entries = append(j.entries, e)
+	}
+	return nil
+}
+
+func (j *MemoryJournal) Info(event string, req []byte, res []byte) error {
+	return j.Log(entry.LevelInfo, event, req, res, nil)
+}
+
+func (j *MemoryJournal) Warn(event string, req []byte, res []byte, err error) error {
+	return j.Log(entry.LevelWarn, event, req, res, err)
+}
+
+func (j *MemoryJournal) Error(event string, req []byte, res []byte, err error) error {
+	return j.Log(entry.LevelError, event, req, res, err)
+}
+
+func (j *MemoryJournal) InfoCtx(ctx context.Context, event string, req []byte, res []byte) error {
+	return j.LogCtx(ctx, entry.LevelInfo, event, req, res, nil)
+}
+
+func (j *MemoryJournal) WarnCtx(ctx context.Context, event string, req []byte, res []byte, err error) error {
+	return j.LogCtx(ctx, entry.LevelWarn, event, req, res, err)
+}
+
+func (j *MemoryJournal) ErrorCtx(ctx context.Context, event string, req []byte, res []byte, err error) error {
+	return j.LogCtx(ctx, entry.LevelError, event, req, res, err)
+}
+
+func (j *MemoryJournal) Rotate() error {
+	return nil
+}
+
+func (j *MemoryJournal) Close() error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.closed = true
+	return nil
+}
+
+// Entries returns all kept entries from the oldest to the newest
+func (j *MemoryJournal) Entries() []*entry.Entry {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+
+	result := make([]*entry.Entry, 0, len(j.entries))
+	result = append(result, j.entries[j.start:]...)
+	return append(result, j.entries[:j.start]...)
+}
+
+func (j *MemoryJournal) Len() int {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+
+	return len(j.entries)
+}
+
+// Last returns the newest entry or nil if journal is empty
+func (j *MemoryJournal) Last() *entry.Entry {
+	entries := j.Entries()
+	if len(entries) == 0 {
+		return nil
+	}
+	return entries[len(entries)-1]
+}
+
+// Search returns entries matched by request the same way as journal service does.
+// Empty ModuleName matches any module, zero Limit means no limit.
+func (j *MemoryJournal) Search(req search.SearchRequest) ([]*entry.Entry, error) {
+	filter, err := search.NewFilter(req)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*entry.Entry, 0)
+	skipped := 0
+	for _, e := range j.Entries() {
+		if ok, err := filter.Match(e); err != nil {
+			return nil, err
+		} else if !ok {
+			continue
+		}
+		if skipped < req.Offset {
+			skipped++
+			continue
+		}
+		result = append(result, e)
+		if req.Limit > 0 && len(result) == req.Limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+func (j *MemoryJournal) Count(req search.SearchRequest) (int, error) {
+	req.Offset = 0
+	req.Limit = 0
+	entries, err := j.Search(req)
+	return len(entries), err
+}
+
+// Reset removes all entries and reopens closed journal
+func (j *MemoryJournal) Reset() {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.entries = nil
+	j.start = 0
+	j.closed = false
+}
+
+func NewMemoryJournal(moduleName, host string, capacity int) *MemoryJournal {
+	return &MemoryJournal{
+		moduleName: moduleName,
+		host:       host,
+		capacity:   capacity,
+	}
+}
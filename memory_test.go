@@ -0,0 +1,46 @@
+package journal
+
+import (
+	"context"
+	"errors"
+	"github.com/integration-system/isp-journal/search"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMemoryJournal(t *testing.T) {
+	a := assert.New(t)
+
+	j := NewMemoryJournal("module", "host", 3)
+	a.NoError(j.Info("first", nil, nil))
+	a.NoError(j.Info("second", []byte("req"), nil))
+	a.NoError(j.Error("third", nil, nil, errors.New("failed")))
+	a.NoError(j.InfoCtx(WithLabel(context.Background(), "tenant", "1"), "fourth", nil, nil))
+
+	entries := j.Entries()
+	a.Len(entries, 3)
+	a.Equal("second", entries[0].Event)
+	a.Equal("fourth", j.Last().Event)
+
+	found, err := j.Search(search.SearchRequest{Level: []string{"ERROR"}})
+	a.NoError(err)
+	a.Len(found, 1)
+	a.Equal("failed", found[0].ErrorText)
+
+	found, err = j.Search(search.SearchRequest{Labels: map[string]string{"tenant": "1"}})
+	a.NoError(err)
+	a.Len(found, 1)
+	a.Equal("fourth", found[0].Event)
+
+	found, err = j.Search(search.SearchRequest{Offset: 1, Limit: 1})
+	a.NoError(err)
+	a.Len(found, 1)
+	a.Equal("third", found[0].Event)
+
+	count, err := j.Count(search.SearchRequest{ModuleName: "other"})
+	a.NoError(err)
+	a.Equal(0, count)
+
+	j.Reset()
+	a.Equal(0, j.Len())
+}
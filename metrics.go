@@ -0,0 +1,74 @@
+package journal
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/integration-system/isp-journal/entry"
+	"github.com/integration-system/isp-journal/log"
+	"net/http"
+	"sort"
+)
+
+const (
+	metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+type metric struct {
+	name  string
+	help  string
+	kind  string
+	value float64
+}
+
+// MetricsHandler exposes journal stats in prometheus text format
+func MetricsHandler(provider StatsProvider) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := provider.Stats()
+
+		w.Header().Set("Content-Type", metricsContentType)
+		buf := bufio.NewWriter(w)
+		defer buf.Flush()
+
+		levels := make([]entry.Level, 0, len(s.Entries))
+		for level := range s.Entries {
+			levels = append(levels, level)
+		}
+		sort.Slice(levels, func(i, j int) bool {
+			if levels[i].Severity() != levels[j].Severity() {
+				return levels[i].Severity() < levels[j].Severity()
+			}
+			return levels[i] < levels[j]
+		})
+		writeHeader(buf, "isp_journal_entries_total", "Entries written to journal by level.", "counter")
+		for _, level := range levels {
+			_, _ = fmt.Fprintf(buf, "isp_journal_entries_total{level=%q} %d\n", string(level), s.Entries[level])
+		}
+
+		metrics := []metric{
+			{"isp_journal_written_bytes_total", "Bytes written to log files.", "counter", float64(s.BytesWritten)},
+			{"isp_journal_write_errors_total", "Failed writes to log files.", "counter", float64(s.WriteErrors)},
+			{"isp_journal_rotations_total", "Log file rotations.", "counter", float64(s.Rotations)},
+			{"isp_journal_rotation_duration_seconds_total", "Time spent in log file rotations.", "counter", s.RotationDuration.Seconds()},
+			{"isp_journal_file_written_bytes", "Bytes written to current log file before compression and encryption.", "gauge", float64(s.CurrentFileWritten)},
+			{"isp_journal_pending_transfer_files", "Rotated log files waiting for transfer.", "gauge", float64(s.PendingTransfer)},
+			{"isp_journal_transfer_failures_total", "Failed transfers of rotated log files.", "counter", float64(s.TransferFailures)},
+			{"isp_journal_dropped_entries_total", "Entries dropped by async journal queue.", "counter", float64(s.Dropped)},
+			{"isp_journal_degrade_dropped_entries_total", "Entries dropped because of low disk space.", "counter", float64(s.DegradeDropped)},
+		}
+		for _, m := range metrics {
+			writeHeader(buf, m.name, m.help, m.kind)
+			_, _ = fmt.Fprintf(buf, "%s %g\n", m.name, m.value)
+		}
+
+		degraded := 0
+		if s.DegradeMode != log.DegradeNone {
+			degraded = 1
+		}
+		writeHeader(buf, "isp_journal_degraded", "Whether journal is in degraded mode because of low disk space.", "gauge")
+		_, _ = fmt.Fprintf(buf, "isp_journal_degraded{mode=%q} %d\n", string(s.DegradeMode), degraded)
+	})
+}
+
+func writeHeader(w *bufio.Writer, name, help, kind string) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
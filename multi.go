@@ -0,0 +1,183 @@
+package journal
+
+import (
+	"context"
+	"fmt"
+	"github.com/integration-system/isp-journal/entry"
+	"strings"
+)
+
+// SinkError describes failure of sink with index Sink in Multi journal
+type SinkError struct {
+	Sink int
+	Err  error
+}
+
+func (e SinkError) Error() string {
+	return fmt.Sprintf("sink %d: %v", e.Sink, e.Err)
+}
+
+type MultiError []SinkError
+
+func (e MultiError) Error() string {
+	errs := make([]string, len(e))
+	for i, err := range e {
+		errs[i] = err.Error()
+	}
+	return strings.Join(errs, "; ")
+}
+
+type multiJournal struct {
+	sinks []*AsyncJournal
+}
+
+// Multi returns journal which writes every entry to all sinks concurrently.
+// Every sink is wrapped into AsyncJournal with drop_newest policy, so slow or hung sink doesn't block
+// writes to others, entries are dropped for sink while its queue is full.
+// Write errors of sinks are logged with sink index, errors of queueing and closing are returned as MultiError.
+func Multi(sinks ...Journal) Journal {
+	m := &multiJournal{sinks: make([]*AsyncJournal, len(sinks))}
+	for i, sink := range sinks {
+		m.sinks[i] = NewAsyncJournal(indexedSink{Journal: sink, index: i}, AsyncConfig{Overflow: OverflowDropNewest})
+	}
+	return m
+}
+
+func (m *multiJournal) Log(level entry.Level, event string, req []byte, res []byte, err error) error {
+	return m.LogCtx(context.Background(), level, event, req, res, err)
+}
+
+func (m *multiJournal) LogCtx(ctx context.Context, level entry.Level, event string, req []byte, res []byte, err error) error {
+	// all sinks must receive the same entry time
+	ctx = withTime(ctx, entryTime(ctx))
+	return m.each(func(sink *AsyncJournal) error {
+		return sink.LogCtx(ctx, level, event, req, res, err)
+	})
+}
+
+func (m *multiJournal) Info(event string, req []byte, res []byte) error {
+	return m.Log(entry.LevelInfo, event, req, res, nil)
+}
+
+func (m *multiJournal) Warn(event string, req []byte, res []byte, err error) error {
+	return m.Log(entry.LevelWarn, event, req, res, err)
+}
+
+func (m *multiJournal) Error(event string, req []byte, res []byte, err error) error {
+	return m.Log(entry.LevelError, event, req, res, err)
+}
+
+func (m *multiJournal) InfoCtx(ctx context.Context, event string, req []byte, res []byte) error {
+	return m.LogCtx(ctx, entry.LevelInfo, event, req, res, nil)
+}
+
+func (m *multiJournal) WarnCtx(ctx context.Context, event string, req []byte, res []byte, err error) error {
+	return m.LogCtx(ctx, entry.LevelWarn, event, req, res, err)
+}
+
+func (m *multiJournal) ErrorCtx(ctx context.Context, event string, req []byte, res []byte, err error) error {
+	return m.LogCtx(ctx, entry.LevelError, event, req, res, err)
+}
+
+func (m *multiJournal) Rotate() error {
+	return m.each(func(sink *AsyncJournal) error {
+		return sink.Rotate()
+	})
+}
+
+// Close waits until queued entries are written to all sinks
+func (m *multiJournal) Close() error {
+	return m.each(func(sink *AsyncJournal) error {
+		return sink.Close()
+	})
+}
+
+func (m *multiJournal) each(f func(sink *AsyncJournal) error) error {
+	var multiErr MultiError
+	for i, sink := range m.sinks {
+		if err := f(sink); err != nil {
+			multiErr = append(multiErr, SinkError{Sink: i, Err: err})
+		}
+	}
+	if len(multiErr) > 0 {
+		return multiErr
+	}
+	return nil
+}
+
+// indexedSink adds sink index to write errors logged by AsyncJournal
+type indexedSink struct {
+	Journal
+	index int
+}
+
+func (s indexedSink) LogCtx(ctx context.Context, level entry.Level, event string, req []byte, res []byte, err error) error {
+	if err := s.Journal.LogCtx(ctx, level, event, req, res, err); err != nil {
+		return SinkError{Sink: s.index, Err: err}
+	}
+	return nil
+}
+
+type levelFilter struct {
+	Journal
+	levels   map[entry.Level]bool
+	minLevel entry.Level
+}
+
+// FilterLevels returns journal which writes to underlying journal only entries with specified levels
+func FilterLevels(journal Journal, levels ...entry.Level) Journal {
+	f := &levelFilter{
+		Journal: journal,
+		levels:  make(map[entry.Level]bool, len(levels)),
+	}
+	for _, level := range levels {
+		f.levels[level] = true
+	}
+	return f
+}
+
+// FilterMinLevel returns journal which writes to underlying journal only entries with level not lower than min
+func FilterMinLevel(journal Journal, min entry.Level) Journal {
+	return &levelFilter{
+		Journal:  journal,
+		minLevel: min,
+	}
+}
+
+func (f *levelFilter) Log(level entry.Level, event string, req []byte, res []byte, err error) error {
+	return f.LogCtx(context.Background(), level, event, req, res, err)
+}
+
+func (f *levelFilter) LogCtx(ctx context.Context, level entry.Level, event string, req []byte, res []byte, err error) error {
+	if f.levels != nil && !f.levels[level] {
+		return nil
+	}
+	if !level.AtLeast(f.minLevel) {
+		return nil
+	}
+	return f.Journal.LogCtx(ctx, level, event, req, res, err)
+}
+
+func (f *levelFilter) Info(event string, req []byte, res []byte) error {
+	return f.Log(entry.LevelInfo, event, req, res, nil)
+}
+
+func (f *levelFilter) Warn(event string, req []byte, res []byte, err error) error {
+	return f.Log(entry.LevelWarn, event, req, res, err)
+}
+
+func (f *levelFilter) Error(event string, req []byte, res []byte, err error) error {
+	return f.Log(entry.LevelError, event, req, res, err)
+}
+
+func (f *levelFilter) InfoCtx(ctx context.Context, event string, req []byte, res []byte) error {
+	return f.LogCtx(ctx, entry.LevelInfo, event, req, res, nil)
+}
+
+func (f *levelFilter) WarnCtx(ctx context.Context, event string, req []byte, res []byte, err error) error {
+	return f.LogCtx(ctx, entry.LevelWarn, event, req, res, err)
+}
+
+func (f *levelFilter) ErrorCtx(ctx context.Context, event string, req []byte, res []byte, err error) error {
+	return f.LogCtx(ctx, entry.LevelError, event, req, res, err)
+}
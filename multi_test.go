@@ -0,0 +1,61 @@
+package journal
+
+import (
+	"context"
+	"errors"
+	"github.com/integration-system/isp-journal/entry"
+	"github.com/stretchr/testify/assert"
+	"runtime"
+	"testing"
+)
+
+type failingJournal struct {
+	Journal
+}
+
+func (failingJournal) LogCtx(ctx context.Context, level entry.Level, event string, req []byte, res []byte, err error) error {
+	return errors.New("disk is full")
+}
+
+func (failingJournal) Close() error {
+	return errors.New("disk is full")
+}
+
+func TestMultiJournal(t *testing.T) {
+	a := assert.New(t)
+
+	all := &stubJournal{}
+	errorsOnly := &stubJournal{}
+	j := Multi(all, failingJournal{}, FilterLevels(errorsOnly, entry.LevelError))
+
+	a.NoError(j.Info("info", nil, nil))
+	a.NoError(j.Error("error", nil, nil, errors.New("error")))
+	err := j.Close()
+	a.Equal(MultiError{{Sink: 1, Err: errors.New("disk is full")}}, err)
+	a.EqualError(err, "sink 1: disk is full")
+
+	a.Equal([]string{"info", "error"}, all.events)
+	a.Equal([]string{"error"}, errorsOnly.events)
+	a.Equal(MultiError{{0, ErrJournalClosed}, {1, ErrJournalClosed}, {2, ErrJournalClosed}}, j.Info("info", nil, nil))
+}
+
+func TestMultiJournalHungSink(t *testing.T) {
+	a := assert.New(t)
+
+	hung := &stubJournal{release: make(chan struct{})}
+	healthy := &stubJournal{}
+	j := Multi(hung, healthy)
+
+	// writes are not blocked by hung sink even when its queue is full
+	for i := 0; i < 2*defaultQueueSize; i++ {
+		a.NoError(j.Info("event", nil, nil))
+		for j.(*multiJournal).sinks[1].Queued() > 0 {
+			runtime.Gosched()
+		}
+	}
+	close(hung.release)
+	a.NoError(j.Close())
+
+	a.Len(healthy.events, 2*defaultQueueSize)
+	a.True(len(hung.events) <= defaultQueueSize+1)
+}
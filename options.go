@@ -1,7 +1,10 @@
 package journal
 
 import (
+	"github.com/integration-system/isp-journal/codes"
 	"github.com/integration-system/isp-journal/log"
+	"github.com/integration-system/isp-journal/redact"
+	logger "github.com/integration-system/isp-log"
 )
 
 type Option func(journal *fileJournal)
@@ -11,3 +14,55 @@ func WithAfterRotation(callback func(log log.LogFile)) Option {
 		journal.afterRotation = callback
 	}
 }
+
+// WithAfterRemoval sets callback called for every rotated file removed by retention policy of log config
+func WithAfterRemoval(callback func(log log.LogFile)) Option {
+	return func(journal *fileJournal) {
+		journal.afterRemoval = callback
+	}
+}
+
+// WithDiskSpaceEvents sets callback called on every switch of degraded mode, it is used if MinFreeSpaceMb is set
+func WithDiskSpaceEvents(callback func(event log.DiskSpaceEvent)) Option {
+	return func(journal *fileJournal) {
+		journal.diskSpaceEvents = callback
+	}
+}
+
+// WithSampling enables sampling, for each entry the first matched rule is applied.
+// Sampling is disabled if any rule is invalid
+func WithSampling(rules ...SamplingRule) Option {
+	return func(journal *fileJournal) {
+		if len(rules) == 0 {
+			return
+		}
+		s, err := newSampler(rules)
+		if err != nil {
+			logger.Errorf(codes.JournalingError, "sampling disabled, invalid rules: %v", err)
+			return
+		}
+		journal.sampler = s
+	}
+}
+
+// WithRedactor masks sensitive data in request, response and error text before entry is written
+func WithRedactor(redactor *redact.Redactor) Option {
+	return func(journal *fileJournal) {
+		journal.redactor = redactor
+	}
+}
+
+// WithMaxPayloadSize limits request and response size, larger payloads are truncated
+func WithMaxPayloadSize(size int) Option {
+	return func(journal *fileJournal) {
+		journal.maxPayloadSize = size
+	}
+}
+
+// WithHashChain makes each entry keep hash of previous one, HMAC is used if key is not empty.
+// Entries are appended to log one by one in chain order, batched writes still wait for batch commit in parallel
+func WithHashChain(key []byte) Option {
+	return func(journal *fileJournal) {
+		journal.chain = &hashChain{key: key}
+	}
+}
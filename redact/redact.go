@@ -0,0 +1,235 @@
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const (
+	defaultMask = "***"
+	pathSplit   = "."
+	anyKey      = "*"
+)
+
+type Config struct {
+	Keys     []string `schema:"Имена полей,значения полей JSON с указанными именами маскируются на любом уровне вложенности, регистр не учитывается"`
+	Paths    []string `schema:"Пути до полей,пути до полей JSON через точку, например user.password, * соответствует любому полю, элементы массивов не образуют отдельный уровень пути"`
+	Patterns []string `schema:"Регулярные выражения,найденные совпадения маскируются в любом содержимом, в том числе не JSON"`
+	Mask     string   `schema:"Маска,значение которым заменяются скрываемые данные, по умолчанию ***"`
+}
+
+type Redactor struct {
+	keys     map[string]bool
+	paths    [][]string
+	patterns []*regexp.Regexp
+	mask     string
+}
+
+// Redact masks sensitive data in payload, JSON payload is returned in compact form
+func (r *Redactor) Redact(data []byte) []byte {
+	if r == nil || len(data) == 0 {
+		return data
+	}
+
+	if len(r.keys) > 0 || len(r.paths) > 0 {
+		if redacted, ok := r.redactJson(data); ok {
+			data = redacted
+		}
+	}
+
+	for _, p := range r.patterns {
+		data = p.ReplaceAllLiteral(data, []byte(r.mask))
+	}
+
+	return data
+}
+
+// RedactText masks regular expression matches in plain text
+func (r *Redactor) RedactText(text string) string {
+	if r == nil {
+		return text
+	}
+	for _, p := range r.patterns {
+		text = p.ReplaceAllLiteralString(text, r.mask)
+	}
+	return text
+}
+
+func (r *Redactor) redactJson(data []byte) ([]byte, bool) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return nil, false
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	dec.UseNumber()
+	buf := bytes.NewBuffer(make([]byte, 0, len(trimmed)))
+	if err := r.writeValue(dec, buf, nil); err != nil {
+		return nil, false
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+func (r *Redactor) writeValue(dec *json.Decoder, buf *bytes.Buffer, path []string) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch value := token.(type) {
+	case json.Delim:
+		switch value {
+		case '{':
+			buf.WriteByte('{')
+			for i := 0; dec.More(); i++ {
+				keyToken, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				key, ok := keyToken.(string)
+				if !ok {
+					return fmt.Errorf("unexpected object key %v", keyToken)
+				}
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				writeString(buf, key)
+				buf.WriteByte(':')
+
+				keyPath := append(path[:len(path):len(path)], key)
+				if r.shouldMask(key, keyPath) {
+					if err := skipValue(dec); err != nil {
+						return err
+					}
+					writeString(buf, r.mask)
+				} else if err := r.writeValue(dec, buf, keyPath); err != nil {
+					return err
+				}
+			}
+			if _, err := dec.Token(); err != nil {
+				return err
+			}
+			buf.WriteByte('}')
+		case '[':
+			buf.WriteByte('[')
+			for i := 0; dec.More(); i++ {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				if err := r.writeValue(dec, buf, path); err != nil {
+					return err
+				}
+			}
+			if _, err := dec.Token(); err != nil {
+				return err
+			}
+			buf.WriteByte(']')
+		default:
+			return fmt.Errorf("unexpected delimiter %v", value)
+		}
+	case string:
+		writeString(buf, value)
+	case json.Number:
+		buf.WriteString(value.String())
+	case bool:
+		if value {
+			buf.WriteString("true")
+		} else {
+			buf.WriteString("false")
+		}
+	case nil:
+		buf.WriteString("null")
+	default:
+		return fmt.Errorf("unexpected token %v", token)
+	}
+
+	return nil
+}
+
+func (r *Redactor) shouldMask(key string, path []string) bool {
+	if r.keys[strings.ToLower(key)] {
+		return true
+	}
+	for _, p := range r.paths {
+		if matchPath(p, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPath(expected, actual []string) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+	for i := range expected {
+		if expected[i] != anyKey && expected[i] != actual[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if delim, ok := token.(json.Delim); ok {
+			switch delim {
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	// Encode always terminates value with new line
+	buf.Truncate(buf.Len() - 1)
+}
+
+func New(cfg Config) (*Redactor, error) {
+	r := &Redactor{
+		keys:     make(map[string]bool, len(cfg.Keys)),
+		paths:    make([][]string, 0, len(cfg.Paths)),
+		patterns: make([]*regexp.Regexp, 0, len(cfg.Patterns)),
+		mask:     cfg.Mask,
+	}
+	if r.mask == "" {
+		r.mask = defaultMask
+	}
+
+	for _, key := range cfg.Keys {
+		r.keys[strings.ToLower(key)] = true
+	}
+	for _, path := range cfg.Paths {
+		r.paths = append(r.paths, strings.Split(path, pathSplit))
+	}
+	for _, pattern := range cfg.Patterns {
+		p, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern '%s': %v", pattern, err)
+		}
+		r.patterns = append(r.patterns, p)
+	}
+
+	return r, nil
+}
@@ -0,0 +1,48 @@
+package redact
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRedactJson(t *testing.T) {
+	a := assert.New(t)
+
+	r, err := New(Config{
+		Keys:  []string{"Password"},
+		Paths: []string{"user.token", "cards.*.number"},
+	})
+	a.NoError(err)
+
+	data := []byte(`{
+		"login": "admin",
+		"password": {"value": "secret"},
+		"user": {"token": "abc", "name": "<b>"},
+		"token": "visible",
+		"cards": [{"main": {"number": "4111", "cvv": 123}}],
+		"list": [1, 2.5, true, null]
+	}`)
+	expected := `{"login":"admin","password":"***","user":{"token":"***","name":"<b>"},"token":"visible",` +
+		`"cards":[{"main":{"number":"***","cvv":123}}],"list":[1,2.5,true,null]}`
+	a.Equal(expected, string(r.Redact(data)))
+}
+
+func TestRedactPatterns(t *testing.T) {
+	a := assert.New(t)
+
+	r, err := New(Config{
+		Keys:     []string{"password"},
+		Patterns: []string{`\d{4}-\d{4}-\d{4}-\d{4}`},
+		Mask:     "#",
+	})
+	a.NoError(err)
+
+	a.Equal(`card 1234-****`, string(r.Redact([]byte(`card 1234-****`))))
+	a.Equal(`card #, password=1`, string(r.Redact([]byte(`card 1111-2222-3333-4444, password=1`))))
+	a.Equal(`{"password":"#","card":"#"}`, string(r.Redact([]byte(`{"password":"1","card":"1111-2222-3333-4444"}`))))
+	a.Equal(`invalid {"password": 1`, string(r.Redact([]byte(`invalid {"password": 1`))))
+	a.Equal("error #", r.RedactText("error 1111-2222-3333-4444"))
+
+	_, err = New(Config{Patterns: []string{"("}})
+	a.Error(err)
+}
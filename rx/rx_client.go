@@ -1,16 +1,19 @@
 package rx
 
 import (
-	"errors"
+	"context"
 	"github.com/integration-system/go-cmp/cmp"
 	"github.com/integration-system/isp-journal"
 	"github.com/integration-system/isp-journal/codes"
 	"github.com/integration-system/isp-journal/entry"
 	"github.com/integration-system/isp-journal/log"
+	"github.com/integration-system/isp-journal/redact"
 	"github.com/integration-system/isp-journal/transfer"
 	"github.com/integration-system/isp-lib/v2/backend"
 	logger "github.com/integration-system/isp-log"
 	"net"
+	"net/http"
+	"sync/atomic"
 )
 
 const (
@@ -18,16 +21,26 @@ const (
 )
 
 var (
-	ErrJournalClosed = errors.New("journal closed")
+	ErrJournalClosed = journal.ErrJournalClosed
 )
 
 type Config struct {
 	log.Config
-	Enable               bool `schema:"Включение/отключение журналирования"`
-	EnableRemoteTransfer bool `schema:"Отгрузка старых журналов,при включении старые файлы журналов будут отгружаются в сервис для isp-journal-service"`
+	Enable               bool                   `schema:"Включение/отключение журналирования"`
+	EnableRemoteTransfer bool                   `schema:"Отгрузка старых журналов,при включении старые файлы журналов будут отгружаются в сервис для isp-journal-service"`
+	Async                bool                   `schema:"Асинхронная запись,при включении записи журналируются в фоне через очередь"`
+	AsyncQueue           journal.AsyncConfig    `schema:"Настройки очереди асинхронной записи"`
+	Sampling             []journal.SamplingRule `schema:"Правила сэмплирования,для каждой записи применяется первое подходящее правило, записи с уровнем ERROR сохраняются всегда"`
+	Redaction            redact.Config          `schema:"Маскирование данных,правила скрытия чувствительных данных в запросах и ответах"`
+	MaxPayloadSizeKb     int                    `schema:"Максимальный размер запроса/ответа,запросы и ответы большего размера сохраняются в журнал обрезанными до указанного размера в килобайтах"`
+	HashChain            bool                   `schema:"Цепочка хешей,при включении каждая запись содержит хеш предыдущей записи, что позволяет обнаружить изменение или удаление записей"`
+	HashChainKey         string                 `schema:"Ключ цепочки хешей,при указании вместо SHA256 используется HMAC-SHA256 с указанным ключом"`
 }
 
 type RxJournal struct {
+	// transferFailures is accessed atomically
+	transferFailures int64
+
 	journal       journal.Journal
 	serviceClient *backend.RxGrpcClient
 	curState      state
@@ -47,9 +60,23 @@ func (j *RxJournal) ReceiveConfiguration(loggerConfig Config, moduleName string)
 		j.curState = newState
 
 		if loggerConfig.Enable {
-			opts := make([]journal.Option, 0)
+			redactor, err := redact.New(loggerConfig.Redaction)
+			if err != nil {
+				logger.Errorf(codes.JournalingError, "journal disabled, invalid redaction config: %v", err)
+				return
+			}
+			opts := []journal.Option{
+				journal.WithSampling(loggerConfig.Sampling...),
+				journal.WithRedactor(redactor),
+				journal.WithMaxPayloadSize(loggerConfig.MaxPayloadSizeKb * 1024),
+			}
+			if loggerConfig.HashChain {
+				opts = append(opts, journal.WithHashChain([]byte(loggerConfig.HashChainKey)))
+			}
 			if loggerConfig.EnableRemoteTransfer {
-				opts = append(opts, journal.WithAfterRotation(transfer.TransferAndDeleteLogFile(j.serviceClient, moduleName, newState.Host)))
+				opts = append(opts, journal.WithAfterRotation(func(f log.LogFile) {
+					j.transfer(f, moduleName, newState.Host)
+				}))
 			}
 			j.journal = journal.NewFileJournal(
 				loggerConfig.Config,
@@ -57,6 +84,9 @@ func (j *RxJournal) ReceiveConfiguration(loggerConfig Config, moduleName string)
 				newState.Host,
 				opts...,
 			)
+			if loggerConfig.Async {
+				j.journal = journal.NewAsyncJournal(j.journal, loggerConfig.AsyncQueue)
+			}
 		}
 	}
 }
@@ -68,16 +98,41 @@ func (j *RxJournal) CollectAndTransferExistedLogs() {
 	}
 
 	logFiles, _ := log.CollectExistedLogs(s.Cfg.Config)
-	if len(logFiles) > 0 {
-		transfer.TransferAndDeleteLogFiles(j.serviceClient, s.ModuleName, s.Host)(logFiles)
+	for _, f := range logFiles {
+		j.transfer(f, s.ModuleName, s.Host)
+	}
+}
+
+func (j *RxJournal) transfer(f log.LogFile, moduleName, host string) {
+	if err := transfer.TransferAndDelete(j.serviceClient, f, moduleName, host); err != nil {
+		atomic.AddInt64(&j.transferFailures, 1)
+	}
+}
+
+// Stats returns stats of current journal, transfer failures are counted since RxJournal creation
+func (j *RxJournal) Stats() journal.Stats {
+	var s journal.Stats
+	if p, ok := j.journal.(journal.StatsProvider); ok {
+		s = p.Stats()
 	}
+	s.TransferFailures += atomic.LoadInt64(&j.transferFailures)
+	return s
+}
+
+// MetricsHandler exposes journal stats in prometheus text format
+func (j *RxJournal) MetricsHandler() http.Handler {
+	return journal.MetricsHandler(j)
 }
 
 func (j *RxJournal) Log(level entry.Level, event string, req []byte, res []byte, err error) error {
+	return j.LogCtx(context.Background(), level, event, req, res, err)
+}
+
+func (j *RxJournal) LogCtx(ctx context.Context, level entry.Level, event string, req []byte, res []byte, err error) error {
 	if j.journal == nil {
 		return nil
 	}
-	return j.journal.Log(level, event, req, res, err)
+	return j.journal.LogCtx(ctx, level, event, req, res, err)
 }
 
 func (j *RxJournal) Info(event string, req []byte, res []byte) error {
@@ -92,6 +147,18 @@ func (j *RxJournal) Error(event string, req []byte, res []byte, err error) error
 	return j.Log(entry.LevelError, event, req, res, err)
 }
 
+func (j *RxJournal) InfoCtx(ctx context.Context, event string, req []byte, res []byte) error {
+	return j.LogCtx(ctx, entry.LevelInfo, event, req, res, nil)
+}
+
+func (j *RxJournal) WarnCtx(ctx context.Context, event string, req []byte, res []byte, err error) error {
+	return j.LogCtx(ctx, entry.LevelWarn, event, req, res, err)
+}
+
+func (j *RxJournal) ErrorCtx(ctx context.Context, event string, req []byte, res []byte, err error) error {
+	return j.LogCtx(ctx, entry.LevelError, event, req, res, err)
+}
+
 func (j *RxJournal) Rotate() error {
 	if j.journal == nil {
 		return ErrJournalClosed
@@ -0,0 +1,80 @@
+package journal
+
+import (
+	"fmt"
+	"github.com/integration-system/isp-journal/entry"
+	"math/rand"
+	"sync/atomic"
+)
+
+type SamplingRule struct {
+	Event   string  `schema:"Событие,имя события к которому применяется правило, при пустом значении правило применяется к любому событию"`
+	Level   string  `schema:"Уровень,уровень записей к которым применяется правило без учета регистра, при пустом значении правило применяется к любому уровню"`
+	Every   int     `schema:"Каждая N-я запись,сохраняется только каждая N-я запись"`
+	Percent float64 `schema:"Процент записей,сохраняется указанный процент записей, выбираемых случайно, при значении 0 сохраняются все записи"`
+}
+
+// samplingRule is SamplingRule with parsed level
+type samplingRule struct {
+	SamplingRule
+	level entry.Level
+}
+
+func (r samplingRule) match(level entry.Level, event string) bool {
+	return (r.Event == "" || r.Event == event) && (r.level == "" || r.level == level)
+}
+
+// sampler applies first matched rule, entries with level ERROR and higher are always kept
+type sampler struct {
+	rules    []samplingRule
+	counters []uint64
+}
+
+// sample returns whether entry should be written and fraction of entries kept by matched rule
+func (s *sampler) sample(level entry.Level, event string) (bool, float64) {
+	if level.AtLeast(entry.LevelError) {
+		return true, 1
+	}
+
+	for i, rule := range s.rules {
+		if !rule.match(level, event) {
+			continue
+		}
+		switch {
+		case rule.Every > 1:
+			n := atomic.AddUint64(&s.counters[i], 1)
+			return (n-1)%uint64(rule.Every) == 0, 1 / float64(rule.Every)
+		case rule.Percent > 0 && rule.Percent < 100:
+			return rand.Float64()*100 < rule.Percent, rule.Percent / 100
+		default:
+			return true, 1
+		}
+	}
+
+	return true, 1
+}
+
+// newSampler validates rules, Every and Percent equal to 0 mean that all matched entries are kept
+func newSampler(rules []SamplingRule) (*sampler, error) {
+	s := &sampler{
+		rules:    make([]samplingRule, len(rules)),
+		counters: make([]uint64, len(rules)),
+	}
+	for i, rule := range rules {
+		s.rules[i].SamplingRule = rule
+		if rule.Level != "" {
+			level, err := entry.ParseLevel(rule.Level)
+			if err != nil {
+				return nil, fmt.Errorf("sampling rule %d: %v", i, err)
+			}
+			s.rules[i].level = level
+		}
+		if rule.Every < 0 {
+			return nil, fmt.Errorf("sampling rule %d: negative every %d", i, rule.Every)
+		}
+		if rule.Percent < 0 || rule.Percent > 100 {
+			return nil, fmt.Errorf("sampling rule %d: percent %g is out of range 0-100", i, rule.Percent)
+		}
+	}
+	return s, nil
+}
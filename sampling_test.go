@@ -0,0 +1,49 @@
+package journal
+
+import (
+	"github.com/integration-system/isp-journal/entry"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSampler(t *testing.T) {
+	a := assert.New(t)
+
+	s, err := newSampler([]SamplingRule{
+		{Event: "health", Every: 3},
+		{Level: "warn", Percent: 100},
+		{Level: "info", Event: "debug", Every: 2},
+		{Percent: 0.000001},
+	})
+	a.NoError(err)
+
+	kept := 0
+	for i := 0; i < 9; i++ {
+		keep, rate := s.sample(entry.LevelInfo, "health")
+		a.InDelta(1.0/3, rate, 0.0001)
+		if keep {
+			kept++
+		}
+	}
+	a.Equal(3, kept)
+
+	keep, rate := s.sample(entry.LevelError, "health")
+	a.True(keep)
+	a.EqualValues(1, rate)
+
+	keep, rate = s.sample(entry.LevelWarn, "other")
+	a.True(keep)
+	a.EqualValues(1, rate)
+
+	// level aliases match case insensitively
+	_, rate = s.sample(entry.LevelInfo, "debug")
+	a.EqualValues(0.5, rate)
+
+	_, rate = s.sample(entry.LevelInfo, "other")
+	a.InDelta(0.00000001, rate, 0.000000001)
+
+	for _, invalid := range []SamplingRule{{Level: "verbose"}, {Every: -1}, {Percent: 150}} {
+		_, err := newSampler([]SamplingRule{invalid})
+		a.Error(err, "%+v", invalid)
+	}
+}
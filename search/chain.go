@@ -0,0 +1,118 @@
+package search
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"github.com/integration-system/isp-journal/encryption"
+	"github.com/integration-system/isp-journal/entry"
+	"io"
+	"os"
+)
+
+const (
+	BreakHashMismatch = "hash mismatch"
+	BreakCorrupted    = "corrupted record"
+	BreakTruncated    = "truncated record"
+)
+
+// ChainBreak describes the first record which doesn't continue hash chain.
+// Offset is position of record in uncompressed data of file.
+type ChainBreak struct {
+	File   string
+	Record int
+	Offset int64
+	Seq    uint64
+	Reason string
+}
+
+func (b ChainBreak) String() string {
+	return fmt.Sprintf("%s: record %d at offset %d (seq %d): %s", b.File, b.Record, b.Offset, b.Seq, b.Reason)
+}
+
+// ChainVerifier checks hash chain through consecutive log files.
+// Previous hash of the first verified record is not checked, since previous record may be removed,
+// as well as removal of the last records of the last file can't be detected.
+type ChainVerifier struct {
+	key      []byte
+	prevHash []byte
+	opts     []ReaderOption
+}
+
+// VerifyFile returns nil if all records of file continue chain
+func (v *ChainVerifier) VerifyFile(filename string) (*ChainBreak, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := NewDecodedReader(f, v.opts...)
+	if err != nil {
+		return nil, fmt.Errorf("could not open log reader %s: %v", filename, err)
+	}
+	defer r.Close()
+	counter := &countingReader{r: r}
+
+	for i := 0; ; i++ {
+		offset := counter.n
+		chainBreak := &ChainBreak{File: filename, Record: i, Offset: offset}
+
+		record, err := entry.ReadNext(counter)
+		if err == io.EOF && counter.n == offset {
+			return nil, nil
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF || err == encryption.ErrTruncated {
+			chainBreak.Reason = BreakTruncated
+			return chainBreak, nil
+		}
+		if err == entry.ErrChecksumMismatch {
+			chainBreak.Reason = BreakCorrupted
+			return chainBreak, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		e := &entry.Entry{}
+		if err := proto.Unmarshal(record, e); err != nil {
+			chainBreak.Reason = BreakCorrupted
+			return chainBreak, nil
+		}
+		chainBreak.Seq = e.Seq
+		if v.prevHash != nil && !bytes.Equal(v.prevHash, e.PrevHash) {
+			chainBreak.Reason = BreakHashMismatch
+			return chainBreak, nil
+		}
+		v.prevHash = entry.ChainHash(v.key, record)
+	}
+}
+
+// NewChainVerifier returns verifier of files written by the same logger,
+// WithCodec option should be set if files have no header
+func NewChainVerifier(key []byte, opts ...ReaderOption) *ChainVerifier {
+	return &ChainVerifier{key: key, opts: opts}
+}
+
+// VerifyChain verifies files in specified order, files must be sorted by creation time,
+// options are used to read every file the same way as by NewChainVerifier
+func VerifyChain(key []byte, files []string, opts ...ReaderOption) (*ChainBreak, error) {
+	v := NewChainVerifier(key, opts...)
+	for _, file := range files {
+		if chainBreak, err := v.VerifyFile(file); err != nil || chainBreak != nil {
+			return chainBreak, err
+		}
+	}
+	return nil, nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,58 @@
+package search
+
+import (
+	"bytes"
+	"github.com/integration-system/isp-journal/entry"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeChain(t *testing.T, filename string, key []byte, prevHash []byte, seqs ...uint64) []byte {
+	buf := bytes.Buffer{}
+	for _, seq := range seqs {
+		record, err := entry.MarshalToBytes(&entry.Entry{Seq: seq, Event: "event", PrevHash: prevHash})
+		assert.NoError(t, err)
+		buf.Write(record)
+		prevHash = entry.ChainHash(key, record[4:])
+	}
+	assert.NoError(t, ioutil.WriteFile(filename, buf.Bytes(), 0644))
+	return prevHash
+}
+
+func TestVerifyChain(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "chain")
+	a.NoError(err)
+	defer os.RemoveAll(dir)
+
+	key := []byte("key")
+	first, second := filepath.Join(dir, "first.log"), filepath.Join(dir, "second.log")
+	prevHash := writeChain(t, first, key, nil, 1, 2, 3)
+	writeChain(t, second, key, prevHash, 4, 5)
+
+	chainBreak, err := VerifyChain(key, []string{first, second})
+	a.NoError(err)
+	a.Nil(chainBreak)
+
+	chainBreak, err = VerifyChain([]byte("other"), []string{first, second})
+	a.NoError(err)
+	a.Equal(&ChainBreak{File: first, Record: 1, Offset: 14, Seq: 2, Reason: BreakHashMismatch}, chainBreak)
+
+	// record 2 is removed
+	writeChain(t, first, key, nil, 1)
+	chainBreak, err = VerifyChain(key, []string{first, second})
+	a.NoError(err)
+	a.Equal(&ChainBreak{File: second, Record: 0, Offset: 0, Seq: 4, Reason: BreakHashMismatch}, chainBreak)
+
+	data, err := ioutil.ReadFile(second)
+	a.NoError(err)
+	a.NoError(ioutil.WriteFile(second, data[:len(data)-3], 0644))
+	chainBreak, err = NewChainVerifier(key).VerifyFile(second)
+	a.NoError(err)
+	a.Equal(BreakTruncated, chainBreak.Reason)
+	a.Equal(1, chainBreak.Record)
+}
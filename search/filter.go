@@ -9,14 +9,20 @@ import (
 
 type (
 	SearchRequest struct {
-		ModuleName string `valid:"required~Required"`
-		From       time.Time
-		To         time.Time
-		Host       []string
-		Event      []string
-		Level      []string
-		Limit      int `valid:"required~Required,range(1|10000)"`
-		Offset     int
+		ModuleName  string `valid:"required~Required"`
+		From        time.Time
+		To          time.Time
+		Host        []string
+		Event       []string
+		Level       []string
+		MinLevel    string
+		TraceId     []string
+		RequestId   []string
+		Labels      map[string]string
+		MinDuration time.Duration
+		MaxDuration time.Duration
+		Limit       int `valid:"required~Required,range(1|10000)"`
+		Offset      int
 	}
 
 	SearchWithCursorRequest struct {
@@ -26,14 +32,25 @@ type (
 	}
 
 	SearchResponse struct {
-		ModuleName string `json:",omitempty"`
-		Host       string `json:",omitempty"`
-		Event      string `json:",omitempty"`
-		Level      string `json:",omitempty"`
-		Time       string `json:",omitempty"`
-		Request    string `json:",omitempty"`
-		Response   string `json:",omitempty"`
-		ErrorText  string `json:",omitempty"`
+		ModuleName     string            `json:",omitempty"`
+		Host           string            `json:",omitempty"`
+		Event          string            `json:",omitempty"`
+		Level          string            `json:",omitempty"`
+		Time           string            `json:",omitempty"`
+		Request        string            `json:",omitempty"`
+		Response       string            `json:",omitempty"`
+		ErrorText      string            `json:",omitempty"`
+		TraceId        string            `json:",omitempty"`
+		SpanId         string            `json:",omitempty"`
+		RequestId      string            `json:",omitempty"`
+		Labels         map[string]string `json:",omitempty"`
+		Duration       time.Duration     `json:",omitempty"`
+		SampleRate     float64           `json:",omitempty"`
+		Truncated      bool              `json:",omitempty"`
+		RequestLength  int64             `json:",omitempty"`
+		ResponseLength int64             `json:",omitempty"`
+		Seq            uint64            `json:",omitempty"`
+		Id             string            `json:",omitempty"`
 	}
 
 	SearchWithCursorResponse struct {
@@ -46,6 +63,14 @@ type (
 		hostByExist  map[string]bool
 		eventByExist map[string]bool
 		levelByExist map[string]bool
+		traceByExist map[string]bool
+		reqIdByExist map[string]bool
+		labels       map[string]string
+
+		minDuration time.Duration
+		maxDuration time.Duration
+
+		minLevel entry.Level
 
 		from time.Time
 		to   time.Time
@@ -59,6 +84,8 @@ func NewFilter(req SearchRequest) (Filter, error) {
 		hostByExist:  make(map[string]bool),
 		eventByExist: make(map[string]bool),
 		levelByExist: make(map[string]bool),
+		traceByExist: make(map[string]bool),
+		reqIdByExist: make(map[string]bool),
 	}
 	for _, value := range req.Host {
 		f.hostByExist[value] = true
@@ -69,6 +96,24 @@ func NewFilter(req SearchRequest) (Filter, error) {
 	for _, value := range req.Level {
 		f.levelByExist[value] = true
 	}
+	for _, value := range req.TraceId {
+		f.traceByExist[value] = true
+	}
+	for _, value := range req.RequestId {
+		f.reqIdByExist[value] = true
+	}
+
+	if req.MinLevel != "" {
+		minLevel, err := entry.ParseLevel(req.MinLevel)
+		if err != nil {
+			return f, status.Error(codes.InvalidArgument, err.Error())
+		}
+		f.minLevel = minLevel
+	}
+
+	f.labels = req.Labels
+	f.minDuration = req.MinDuration
+	f.maxDuration = req.MaxDuration
 
 	if err := f.defineTimeForSearch(req.From, req.To); err != nil {
 		return f, err
@@ -105,6 +150,17 @@ func (f *Filter) checkTimeField(timeString string) (bool, error) {
 	return false, nil
 }
 
+// Match reports whether entry satisfies all search request conditions
+func (f *Filter) Match(e *entry.Entry) (bool, error) {
+	if f.moduleName != "" && f.moduleName != e.ModuleName {
+		return false, nil
+	}
+	if !f.checkEntry(e) {
+		return false, nil
+	}
+	return f.checkTimeField(e.Time)
+}
+
 func (f *Filter) checkEntry(entries *entry.Entry) bool {
 	if !f.checkLevel(entries.Level) {
 		return false
@@ -115,11 +171,43 @@ func (f *Filter) checkEntry(entries *entry.Entry) bool {
 	if !f.checkHost(entries.Host) {
 		return false
 	}
+	if !f.checkEntryField(f.traceByExist, entries.TraceId) {
+		return false
+	}
+	if !f.checkEntryField(f.reqIdByExist, entries.RequestId) {
+		return false
+	}
+	if !f.checkLabels(entries.Labels) {
+		return false
+	}
+	if !f.checkDuration(time.Duration(entries.Duration)) {
+		return false
+	}
+	return true
+}
+
+func (f *Filter) checkDuration(duration time.Duration) bool {
+	if f.minDuration > 0 && duration < f.minDuration {
+		return false
+	}
+	if f.maxDuration > 0 && duration > f.maxDuration {
+		return false
+	}
+	return true
+}
+
+// checkLabels expects all requested labels present in entry with the same values
+func (f *Filter) checkLabels(labels map[string]string) bool {
+	for key, value := range f.labels {
+		if actual, ok := labels[key]; !ok || actual != value {
+			return false
+		}
+	}
 	return true
 }
 
 func (f *Filter) checkLevel(level string) bool {
-	return f.checkEntryField(f.levelByExist, level)
+	return f.checkEntryField(f.levelByExist, level) && entry.Level(level).AtLeast(f.minLevel)
 }
 
 func (f *Filter) checkEvent(event string) bool {
@@ -138,3 +226,34 @@ func (f *Filter) checkEntryField(expected map[string]bool, field string) bool {
 	}
 	return false
 }
+
+// matchFile reports whether file may contain matched entries, it is always true for files without trailer
+func (f *Filter) matchFile(header *entry.FileHeader, trailer *entry.FileTrailer) bool {
+	if header == nil {
+		return true
+	}
+	if f.moduleName != "" && header.ModuleName != "" && f.moduleName != header.ModuleName {
+		return false
+	}
+	if header.Host != "" && !f.checkHost(header.Host) {
+		return false
+	}
+	if trailer == nil {
+		return true
+	}
+	if trailer.Entries == 0 {
+		return false
+	}
+	if maxTime, err := entry.ParserTime(trailer.MaxTime); err == nil && maxTime.Before(f.from) {
+		return false
+	}
+	if minTime, err := entry.ParserTime(trailer.MinTime); err == nil && minTime.After(f.to) {
+		return false
+	}
+	for level, count := range trailer.Levels {
+		if count > 0 && f.checkLevel(level) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package search
+
+import (
+	"github.com/integration-system/isp-journal/entry"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestFilterCheckLabels(t *testing.T) {
+	a := assert.New(t)
+
+	filter, err := NewFilter(SearchRequest{
+		ModuleName: "module",
+		Labels:     map[string]string{"tenant": "1", "client": "web"},
+	})
+	a.NoError(err)
+
+	a.True(filter.checkEntry(&entry.Entry{Labels: map[string]string{"tenant": "1", "client": "web", "other": "x"}}))
+	a.False(filter.checkEntry(&entry.Entry{Labels: map[string]string{"tenant": "1"}}))
+	a.False(filter.checkEntry(&entry.Entry{Labels: map[string]string{"tenant": "2", "client": "web"}}))
+	a.False(filter.checkEntry(&entry.Entry{}))
+
+	filter, err = NewFilter(SearchRequest{ModuleName: "module"})
+	a.NoError(err)
+	a.True(filter.checkEntry(&entry.Entry{}))
+}
+
+func TestFilterCheckDuration(t *testing.T) {
+	a := assert.New(t)
+
+	filter, err := NewFilter(SearchRequest{
+		ModuleName:  "module",
+		MinDuration: 100 * time.Millisecond,
+		MaxDuration: time.Second,
+	})
+	a.NoError(err)
+
+	a.False(filter.checkEntry(&entry.Entry{Duration: int64(50 * time.Millisecond)}))
+	a.True(filter.checkEntry(&entry.Entry{Duration: int64(100 * time.Millisecond)}))
+	a.True(filter.checkEntry(&entry.Entry{Duration: int64(time.Second)}))
+	a.False(filter.checkEntry(&entry.Entry{Duration: int64(2 * time.Second)}))
+}
+
+func TestFilterCheckMinLevel(t *testing.T) {
+	a := assert.New(t)
+
+	filter, err := NewFilter(SearchRequest{
+		ModuleName: "module",
+		MinLevel:   "warn",
+		Level:      []string{"OK", "WARN", "FATAL"},
+	})
+	a.NoError(err)
+
+	a.False(filter.checkEntry(&entry.Entry{Level: string(entry.LevelInfo)}))
+	a.True(filter.checkEntry(&entry.Entry{Level: string(entry.LevelWarn)}))
+	a.False(filter.checkEntry(&entry.Entry{Level: string(entry.LevelError)}))
+	a.True(filter.checkEntry(&entry.Entry{Level: string(entry.LevelFatal)}))
+
+	_, err = NewFilter(SearchRequest{ModuleName: "module", MinLevel: "unknown"})
+	a.Error(err)
+}
+
+func TestFilterMatchFile(t *testing.T) {
+	a := assert.New(t)
+
+	filter, err := NewFilter(SearchRequest{
+		ModuleName: "module",
+		From:       time.Date(2020, 11, 1, 10, 0, 0, 0, time.UTC),
+		To:         time.Date(2020, 11, 1, 11, 0, 0, 0, time.UTC),
+		MinLevel:   "WARN",
+	})
+	a.NoError(err)
+
+	header := &entry.FileHeader{ModuleName: "module", Host: "host"}
+	trailer := func(min, max time.Time, levels map[string]int64) *entry.FileTrailer {
+		return &entry.FileTrailer{Entries: 1, MinTime: entry.FormatTime(min), MaxTime: entry.FormatTime(max), Levels: levels}
+	}
+	inRange := time.Date(2020, 11, 1, 10, 30, 0, 0, time.UTC)
+	errorLevels := map[string]int64{"ERROR": 1}
+
+	a.True(filter.matchFile(nil, nil))
+	a.True(filter.matchFile(header, nil))
+	a.True(filter.matchFile(header, trailer(inRange, inRange, errorLevels)))
+	a.False(filter.matchFile(&entry.FileHeader{ModuleName: "other"}, nil))
+	a.False(filter.matchFile(header, &entry.FileTrailer{}))
+	a.False(filter.matchFile(header, trailer(inRange, inRange, map[string]int64{"OK": 1})))
+	a.False(filter.matchFile(header, trailer(inRange.Add(-2*time.Hour), inRange.Add(-time.Hour), errorLevels)))
+	a.False(filter.matchFile(header, trailer(inRange.Add(time.Hour), inRange.Add(2*time.Hour), errorLevels)))
+	a.True(filter.matchFile(header, trailer(inRange.Add(-time.Hour), inRange.Add(time.Hour), errorLevels)))
+}
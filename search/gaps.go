@@ -0,0 +1,90 @@
+package search
+
+import (
+	"github.com/integration-system/isp-journal/entry"
+	"sort"
+)
+
+// Gap is range of missing sequence numbers [From, To] of module on host
+type Gap struct {
+	ModuleName string
+	Host       string
+	From       uint64
+	To         uint64
+}
+
+type seqSource struct {
+	moduleName string
+	host       string
+}
+
+// GapDetector collects sequence numbers of entries and reports missing ones.
+// Entries can be added in any order, entries without sequence number are ignored.
+type GapDetector struct {
+	seqs map[seqSource][]uint64
+}
+
+func (d *GapDetector) Add(e *entry.Entry) {
+	if e.Seq == 0 {
+		return
+	}
+	source := seqSource{moduleName: e.ModuleName, host: e.Host}
+	d.seqs[source] = append(d.seqs[source], e.Seq)
+}
+
+// Gaps returns missing ranges between the smallest and the biggest sequence number of each source
+func (d *GapDetector) Gaps() []Gap {
+	sources := make([]seqSource, 0, len(d.seqs))
+	for source := range d.seqs {
+		sources = append(sources, source)
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		if sources[i].moduleName != sources[j].moduleName {
+			return sources[i].moduleName < sources[j].moduleName
+		}
+		return sources[i].host < sources[j].host
+	})
+
+	gaps := make([]Gap, 0)
+	for _, source := range sources {
+		seqs := d.seqs[source]
+		sort.Slice(seqs, func(i, j int) bool {
+			return seqs[i] < seqs[j]
+		})
+		for i := 1; i < len(seqs); i++ {
+			if seqs[i] > seqs[i-1]+1 {
+				gaps = append(gaps, Gap{
+					ModuleName: source.moduleName,
+					Host:       source.host,
+					From:       seqs[i-1] + 1,
+					To:         seqs[i] - 1,
+				})
+			}
+		}
+	}
+	return gaps
+}
+
+func NewGapDetector() *GapDetector {
+	return &GapDetector{seqs: make(map[seqSource][]uint64)}
+}
+
+// FindGaps reads all entries matched to request and returns missing sequence numbers
+func FindGaps(req SearchRequest, baseDir string, opts ...ReaderOption) ([]Gap, error) {
+	s, err := NewSyncSearchService(req, baseDir, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	d := NewGapDetector()
+	for {
+		e, hasMore, err := s.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !hasMore {
+			return d.Gaps(), nil
+		}
+		d.Add(e)
+	}
+}
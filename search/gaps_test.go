@@ -0,0 +1,24 @@
+package search
+
+import (
+	"github.com/integration-system/isp-journal/entry"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGapDetector(t *testing.T) {
+	a := assert.New(t)
+
+	d := NewGapDetector()
+	for _, seq := range []uint64{5, 1, 2, 9, 6, 0} {
+		d.Add(&entry.Entry{ModuleName: "module", Host: "b", Seq: seq})
+	}
+	for _, seq := range []uint64{3, 4, 2} {
+		d.Add(&entry.Entry{ModuleName: "module", Host: "a", Seq: seq})
+	}
+
+	a.Equal([]Gap{
+		{ModuleName: "module", Host: "b", From: 3, To: 4},
+		{ModuleName: "module", Host: "b", From: 7, To: 8},
+	}, d.Gaps())
+}
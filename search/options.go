@@ -0,0 +1,69 @@
+package search
+
+import (
+	"github.com/integration-system/isp-journal/codec"
+	"github.com/integration-system/isp-journal/entry"
+)
+
+type ReaderOption func(o *readerOptions)
+
+type readerOptions struct {
+	keyProvider func() ([]byte, error)
+	codec       codec.Codec
+	skipDamaged bool
+	onSkip      func(file string, r entry.SkippedRange)
+	file        string
+}
+
+// WithDecryptionKey sets key to read encrypted log files
+func WithDecryptionKey(key []byte) ReaderOption {
+	return func(o *readerOptions) {
+		o.keyProvider = func() ([]byte, error) {
+			return key, nil
+		}
+	}
+}
+
+// WithKeyProvider sets function returning key to read encrypted log files, it is called for every encrypted file
+func WithKeyProvider(provider func() ([]byte, error)) ReaderOption {
+	return func(o *readerOptions) {
+		o.keyProvider = provider
+	}
+}
+
+// WithCodec sets codec of files without header, so compression is not detected by magic bytes
+func WithCodec(c codec.Codec) ReaderOption {
+	return func(o *readerOptions) {
+		o.codec = c
+	}
+}
+
+// WithSkipDamaged enables corruption tolerant reading: damaged records and unreadable files are skipped
+// instead of aborting search, onSkip may be nil
+func WithSkipDamaged(onSkip func(file string, r entry.SkippedRange)) ReaderOption {
+	return func(o *readerOptions) {
+		o.skipDamaged = true
+		o.onSkip = onSkip
+	}
+}
+
+// withFile sets name of file passed to skip handler
+func withFile(file string) ReaderOption {
+	return func(o *readerOptions) {
+		o.file = file
+	}
+}
+
+func (o readerOptions) skip(r entry.SkippedRange) {
+	if o.onSkip != nil {
+		o.onSkip(o.file, r)
+	}
+}
+
+func newReaderOptions(opts []ReaderOption) readerOptions {
+	o := readerOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
@@ -1,9 +1,9 @@
 package search
 
 import (
-	"bufio"
-	"compress/gzip"
+	"github.com/golang/protobuf/proto"
 	io2 "github.com/integration-system/isp-io"
+	"github.com/integration-system/isp-journal/codec"
 	"github.com/integration-system/isp-journal/entry"
 	"io"
 )
@@ -11,28 +11,55 @@ import (
 type logReader struct {
 	filter Filter
 	reader io2.ReadPipe
+	// scanner is set if damaged records are skipped
+	scanner *entry.RecordScanner
 }
 
-func NewLogReader(reader io.Reader, gzipped bool, filter Filter) (*logReader, error) {
-	pipe := io2.NewReadPipe(reader)
-
-	pipe.Unshift(bufio.NewReaderSize(pipe.Last(), bufSize))
+// NewLogReader reads compressed data if gzipped is set, codec is taken from file header or WithCodec option,
+// otherwise it is detected by magic bytes and gzip is assumed if data has unknown format.
+// Data is read as is if gzipped is not set and codec is not specified
+func NewLogReader(reader io.Reader, gzipped bool, filter Filter, opts ...ReaderOption) (*logReader, error) {
+	defaultCodec := codec.None
 	if gzipped {
-		if gzipReader, err := gzip.NewReader(pipe.Last()); err != nil {
-			return nil, err
-		} else {
-			pipe.Unshift(gzipReader)
-		}
+		defaultCodec = codec.Gzip
 	}
-
-	return &logReader{
+	o := newReaderOptions(opts)
+	if !gzipped && o.codec == codec.Unknown {
+		o.codec = codec.None
+	}
+	pipe, err := newFilePipe(reader, defaultCodec, o)
+	if err != nil {
+		return nil, err
+	}
+	r := &logReader{
 		reader: pipe,
 		filter: filter,
-	}, nil
+	}
+	if o.skipDamaged {
+		r.scanner = entry.NewRecordScanner(pipe, o.skip)
+	}
+	return r, nil
+}
+
+// NewDecodedReader returns reader of marshaled entries, file header and trailer are skipped,
+// encryption is detected by magic bytes, codec is taken from file header or WithCodec option,
+// otherwise it is detected by magic bytes
+func NewDecodedReader(reader io.Reader, opts ...ReaderOption) (io2.ReadPipe, error) {
+	return newFilePipe(reader, codec.None, newReaderOptions(opts))
+}
+
+func newFilePipe(reader io.Reader, defaultCodec codec.Codec, o readerOptions) (io2.ReadPipe, error) {
+	fileReader, err := entry.NewFileReader(reader, o.keyProvider, o.codec, defaultCodec)
+	if err != nil {
+		return nil, err
+	}
+	pipe := io2.NewReadPipe(reader)
+	pipe.Unshift(fileReader)
+	return pipe, nil
 }
 
 func (s *logReader) FilterNext() (*entry.Entry, error) {
-	if extractedEntry, err := entry.UnmarshalNext(s.reader); err != nil {
+	if extractedEntry, err := s.next(); err != nil {
 		return nil, err
 	} else if s.filter.checkEntry(extractedEntry) {
 		if ok, err := s.filter.checkTimeField(extractedEntry.Time); err != nil || !ok {
@@ -43,6 +70,21 @@ func (s *logReader) FilterNext() (*entry.Entry, error) {
 	return nil, nil
 }
 
+func (s *logReader) next() (*entry.Entry, error) {
+	if s.scanner == nil {
+		return entry.UnmarshalNext(s.reader)
+	}
+	record, err := s.scanner.Next()
+	if err != nil {
+		return nil, err
+	}
+	e := &entry.Entry{}
+	if err := proto.Unmarshal(record, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
 func (s *logReader) Close() error {
 	return s.reader.Close()
 }
@@ -0,0 +1,86 @@
+package search
+
+import (
+	"bytes"
+	"github.com/integration-system/isp-journal/entry"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestLogReaderPlainLooksLikeGzip(t *testing.T) {
+	a := assert.New(t)
+
+	filter, err := NewFilter(SearchRequest{ModuleName: "module", From: time.Unix(0, 0)})
+	a.NoError(err)
+
+	// length prefix 0x8b1f starts with gzip magic
+	e := &entry.Entry{
+		ModuleName: "module",
+		Level:      string(entry.LevelInfo),
+		Time:       entry.FormatTime(time.Now().UTC()),
+	}
+	record, err := entry.MarshalToBytes(e)
+	a.NoError(err)
+	// request field tag and length take 4 bytes, as much as length prefix
+	e.Request = make([]byte, 0x8b1f-len(record))
+	record, err = entry.MarshalToBytes(e)
+	a.NoError(err)
+	a.Equal([]byte{0x1f, 0x8b}, record[:2])
+
+	r, err := NewLogReader(bytes.NewReader(record), false, filter)
+	a.NoError(err)
+	read, err := r.FilterNext()
+	a.NoError(err)
+	a.Len(read.Request, len(e.Request))
+}
+
+func TestLogReaderSkipDamaged(t *testing.T) {
+	a := assert.New(t)
+
+	filter, err := NewFilter(SearchRequest{ModuleName: "module", From: time.Unix(0, 0)})
+	a.NoError(err)
+
+	data := bytes.Buffer{}
+	for _, event := range []string{"first", "damaged", "last"} {
+		record, err := entry.MarshalToBytesWithChecksum(&entry.Entry{
+			ModuleName: "module",
+			Event:      event,
+			Level:      string(entry.LevelInfo),
+			Time:       entry.FormatTime(time.Now().UTC()),
+		})
+		a.NoError(err)
+		data.Write(record)
+	}
+	stream := data.Bytes()
+	damagedAt := bytes.Index(stream, []byte("damaged"))
+	stream[damagedAt] = 'D'
+
+	r, err := NewLogReader(bytes.NewReader(stream), false, filter)
+	a.NoError(err)
+	e, err := r.FilterNext()
+	a.NoError(err)
+	a.Equal("first", e.Event)
+	_, err = r.FilterNext()
+	a.Equal(entry.ErrChecksumMismatch, err)
+
+	skipped := make([]entry.SkippedRange, 0)
+	r, err = NewLogReader(bytes.NewReader(stream), false, filter, WithSkipDamaged(func(file string, r entry.SkippedRange) {
+		skipped = append(skipped, r)
+	}))
+	a.NoError(err)
+	events := make([]string, 0)
+	for {
+		e, err := r.FilterNext()
+		if err == io.EOF {
+			break
+		}
+		a.NoError(err)
+		events = append(events, e.Event)
+	}
+	a.Equal([]string{"first", "last"}, events)
+	a.Len(skipped, 1)
+	a.Equal(entry.SkipChecksumMismatch, skipped[0].Reason)
+	a.True(skipped[0].From < int64(damagedAt) && int64(damagedAt) < skipped[0].To)
+}
@@ -8,18 +8,20 @@ type searchLog struct {
 	entriesHandler func(*entry.Entry) (bool, error)
 	s              *SyncSearchLog
 	baseDir        string
+	opts           []ReaderOption
 }
 
-func NewSearchLog(entriesHandler func(*entry.Entry) (continueRead bool, err error), baseDir string) *searchLog {
+func NewSearchLog(entriesHandler func(*entry.Entry) (continueRead bool, err error), baseDir string, opts ...ReaderOption) *searchLog {
 	return &searchLog{
 		entriesHandler: entriesHandler,
 		baseDir:        baseDir,
+		opts:           opts,
 	}
 }
 
 func (s *searchLog) Search(req SearchRequest) error {
 	var err error
-	if s.s, err = NewSyncSearchService(req, s.baseDir); err != nil {
+	if s.s, err = NewSyncSearchService(req, s.baseDir, s.opts...); err != nil {
 		return err
 	} else if err := s.extractData(); err != nil {
 		return err
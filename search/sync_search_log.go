@@ -11,17 +11,21 @@ type SyncSearchLog struct {
 	filter        Filter
 	files         []string
 	currentReader *logReader
+	opts          []ReaderOption
+	options       readerOptions
 }
 
-func NewSyncSearchService(req SearchRequest, baseDir string) (*SyncSearchLog, error) {
+func NewSyncSearchService(req SearchRequest, baseDir string, opts ...ReaderOption) (*SyncSearchLog, error) {
 	if filter, err := NewFilter(req); err != nil {
 		return nil, err
 	} else if files, err := findAllMatchedFiles(filter, baseDir); err != nil {
 		return nil, err
 	} else {
 		return &SyncSearchLog{
-			filter: filter,
-			files:  files,
+			filter:  filter,
+			files:   files,
+			opts:    opts,
+			options: newReaderOptions(opts),
 		}, nil
 	}
 }
@@ -63,12 +67,24 @@ func (s *SyncSearchLog) openNextReader() (bool, error) {
 		if err != nil {
 			return false, fmt.Errorf("could not open file %s: %v", currentFile, err)
 		}
-		currentReader, err := NewLogReader(file, true, s.filter)
+		if !s.matchFile(file) {
+			_ = file.Close()
+			s.files = files
+			continue
+		}
+		currentReader, err := NewLogReader(file, true, s.filter, append(s.opts, withFile(currentFile))...)
 		if err != nil {
 			if err == io.EOF {
 				s.files = files
 				continue
 			}
+			if s.options.skipDamaged {
+				_ = file.Close()
+				s.options.file = currentFile
+				s.options.skip(entry.SkippedRange{From: 0, To: -1, Reason: entry.SkipUnreadableStream + ": " + err.Error()})
+				s.files = files
+				continue
+			}
 			return false, fmt.Errorf("could not open log reader %s: %v", currentFile, err)
 		}
 		s.files = files
@@ -76,3 +92,16 @@ func (s *SyncSearchLog) openNextReader() (bool, error) {
 		return true, err
 	}
 }
+
+// matchFile uses file header and trailer to skip files without reading entries
+func (s *SyncSearchLog) matchFile(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return true
+	}
+	header, trailer, err := entry.ReadFileSummary(file, info.Size())
+	if err != nil {
+		return true
+	}
+	return s.filter.matchFile(header, trailer)
+}
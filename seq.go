@@ -0,0 +1,127 @@
+package journal
+
+import (
+	"encoding/json"
+	"github.com/golang/protobuf/proto"
+	"github.com/integration-system/isp-journal/codec"
+	"github.com/integration-system/isp-journal/codes"
+	"github.com/integration-system/isp-journal/entry"
+	"github.com/integration-system/isp-journal/log"
+	"github.com/integration-system/isp-journal/search"
+	logger "github.com/integration-system/isp-log"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// journalState is saved next to active log file, since rotated files may be transferred and removed
+type journalState struct {
+	Seq      uint64 `json:"seq"`
+	PrevHash []byte `json:"prevHash,omitempty"`
+}
+
+func stateFilename(config log.Config) string {
+	return config.GetFilename() + ".state"
+}
+
+// readState returns zero state if it was never saved or can't be read
+func readState(config log.Config) journalState {
+	state := journalState{}
+	filename := stateFilename(config)
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warnf(codes.JournalingError, "could not read journal state '%s': %v", filename, err)
+		}
+		return state
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		logger.Warnf(codes.JournalingError, "invalid journal state '%s', sequence is restored from log files: %v", filename, err)
+		return journalState{}
+	}
+	return state
+}
+
+// saveState replaces state file atomically, so state is never partially written
+func saveState(config log.Config, state journalState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	filename := stateFilename(config)
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filename)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// lastRecord returns max sequence number and the last marshaled entry written to
+// active log file or, if it has no entries, to the newest rotated file. Files which were
+// transferred and removed can't be checked, so saved state is used when no files left.
+// It must be called before logger is created, since logger rotates active file left by previous process
+// and the file may be transferred and removed right after that.
+func lastRecord(config log.Config) (uint64, []byte) {
+	if seq, record := scanFile(config, config.GetFilename(), config.GetCodec()); record != nil {
+		return seq, record
+	}
+
+	logs, err := log.CollectExistedLogs(config)
+	if err != nil || len(logs) == 0 {
+		return 0, nil
+	}
+	newest := logs[0]
+	for _, l := range logs[1:] {
+		if l.CreatedAt.After(newest.CreatedAt) {
+			newest = l
+		}
+	}
+	return scanFile(config, newest.FullPath, newest.Codec)
+}
+
+// scanFile reads all valid entries of file
+func scanFile(config log.Config, filename string, c codec.Codec) (uint64, []byte) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return 0, nil
+	}
+	defer f.Close()
+
+	opts := []search.ReaderOption{search.WithCodec(c)}
+	if config.IsEncrypted() {
+		opts = append(opts, search.WithKeyProvider(config.GetEncryptionKey))
+	}
+	r, err := search.NewDecodedReader(f, opts...)
+	if err != nil {
+		return 0, nil
+	}
+	defer r.Close()
+
+	// damaged records are skipped the same way as logger recovers active file
+	s := entry.NewRecordScanner(r, nil)
+	seq, last := uint64(0), []byte(nil)
+	for {
+		record, err := s.Next()
+		if err != nil {
+			return seq, last
+		}
+		e := &entry.Entry{}
+		if err := proto.Unmarshal(record, e); err != nil {
+			return seq, last
+		}
+		last = record
+		if e.Seq > seq {
+			seq = e.Seq
+		}
+	}
+}
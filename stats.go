@@ -0,0 +1,77 @@
+package journal
+
+import (
+	"github.com/integration-system/isp-journal/entry"
+	"github.com/integration-system/isp-journal/log"
+	"sync"
+	"sync/atomic"
+)
+
+// Stats contains counters and gauges of journal
+type Stats struct {
+	log.Stats
+	// Entries is count of written entries by level
+	Entries map[entry.Level]int64
+	// PendingTransfer is count of rotated log files which are still on disk,
+	// it is updated on rotation and removal by retention policy
+	PendingTransfer int
+	// TransferFailures is count of failed transfers of rotated log files
+	TransferFailures int64
+	// Dropped is count of entries discarded by async journal queue
+	Dropped int64
+	// DegradeDropped is count of entries discarded because of low disk space
+	DegradeDropped int64
+	// DegradeMode is current mode of disk guard, it is empty in normal mode
+	DegradeMode log.DegradeMode
+}
+
+type StatsProvider interface {
+	Stats() Stats
+}
+
+type entryCounter struct {
+	lock    sync.Mutex
+	entries map[entry.Level]int64
+}
+
+func (c *entryCounter) inc(level entry.Level) {
+	c.lock.Lock()
+	c.entries[level]++
+	c.lock.Unlock()
+}
+
+func (c *entryCounter) snapshot() map[entry.Level]int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	entries := make(map[entry.Level]int64, len(c.entries))
+	for level, count := range c.entries {
+		entries[level] = count
+	}
+	return entries
+}
+
+func newEntryCounter() *entryCounter {
+	return &entryCounter{entries: make(map[entry.Level]int64)}
+}
+
+func (j *fileJournal) Stats() Stats {
+	s := Stats{
+		Stats:          j.log.Stats(),
+		Entries:        j.counter.snapshot(),
+		DegradeDropped: atomic.LoadInt64(&j.degradeDropped),
+		DegradeMode:    j.guard.Mode(),
+	}
+	s.PendingTransfer = int(atomic.LoadInt64(&j.pendingTransfer))
+	return s
+}
+
+// Stats returns stats of underlying journal if it implements StatsProvider with count of dropped entries
+func (j *AsyncJournal) Stats() Stats {
+	var s Stats
+	if p, ok := j.journal.(StatsProvider); ok {
+		s = p.Stats()
+	}
+	s.Dropped += j.Dropped()
+	return s
+}
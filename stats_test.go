@@ -0,0 +1,52 @@
+package journal
+
+import (
+	"github.com/integration-system/isp-journal/entry"
+	"github.com/integration-system/isp-journal/log"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileJournalStats(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "journal")
+	a.NoError(err)
+	defer os.RemoveAll(dir)
+
+	cfg := log.Config{
+		Filename:  filepath.Join(dir, "test.log"),
+		MaxSizeMb: 1,
+	}
+	j := NewFileJournal(cfg, "module", "host")
+	defer j.Close()
+
+	a.NoError(j.Info("event", []byte("request"), []byte("response")))
+	a.NoError(j.Info("event", []byte("request"), []byte("response")))
+	a.NoError(j.Error("event", nil, nil, os.ErrNotExist))
+	a.NoError(j.Rotate())
+
+	s := j.(StatsProvider).Stats()
+	a.EqualValues(2, s.Entries[entry.LevelInfo])
+	a.EqualValues(1, s.Entries[entry.LevelError])
+	a.True(s.BytesWritten > 0)
+	a.EqualValues(1, s.Rotations)
+	a.EqualValues(0, s.CurrentFileWritten)
+	// pending files are counted by rotation callback
+	a.Eventually(func() bool {
+		return j.(StatsProvider).Stats().PendingTransfer == 1
+	}, time.Second, time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	MetricsHandler(j.(StatsProvider)).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	a.Contains(body, "# TYPE isp_journal_entries_total counter\n")
+	a.Contains(body, "isp_journal_entries_total{level=\"OK\"} 2\nisp_journal_entries_total{level=\"ERROR\"} 1\n")
+	a.Contains(body, "isp_journal_rotations_total 1\n")
+	a.Contains(body, "isp_journal_pending_transfer_files 1\n")
+}
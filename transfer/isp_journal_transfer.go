@@ -2,6 +2,7 @@ package transfer
 
 import (
 	"fmt"
+	"github.com/integration-system/isp-journal/codec"
 	"github.com/integration-system/isp-journal/codes"
 	"github.com/integration-system/isp-journal/entry"
 	"github.com/integration-system/isp-journal/log"
@@ -19,28 +20,28 @@ const (
 	moduleNameField = "moduleName"
 	createdAtField  = "createdAt"
 	hostField       = "host"
-
-	gzipContent   = "application/gzip"
-	binaryContent = "application/binary"
+	encryptedField  = "encrypted"
 )
 
 type LogInfo struct {
 	ModuleName string
 	Host       string
 	Compressed bool
+	Codec      codec.Codec
+	Encrypted  bool
 	CreatedAt  time.Time
 }
 
 func TransferAndDeleteLogFile(client *backend.RxGrpcClient, moduleName, host string) func(file log.LogFile) {
 	return func(log log.LogFile) {
-		doTransfer(client, log, moduleName, host)
+		_ = TransferAndDelete(client, log, moduleName, host)
 	}
 }
 
 func TransferAndDeleteLogFiles(client *backend.RxGrpcClient, moduleName, host string) func(logs []log.LogFile) {
 	return func(logs []log.LogFile) {
 		for _, f := range logs {
-			doTransfer(client, f, moduleName, host)
+			_ = TransferAndDelete(client, f, moduleName, host)
 		}
 	}
 }
@@ -66,31 +67,37 @@ func GetLogInfo(bf streaming.BeginFile) (*LogInfo, error) {
 		return nil, fmt.Errorf("invalid '%s' time format: %v", createdAtField, err)
 	}
 
-	compressed := false
-	if bf.ContentType == gzipContent {
-		compressed = true
-	}
+	fileCodec := codec.ByContentType(bf.ContentType)
+	// field is absent in files transferred by older versions
+	encrypted, _ := bf.FormData[encryptedField].(bool)
 
 	return &LogInfo{
 		ModuleName: moduleName,
 		Host:       host,
-		Compressed: compressed,
+		Compressed: fileCodec != codec.None,
+		Codec:      fileCodec,
+		Encrypted:  encrypted,
 		CreatedAt:  createdAtTime,
 	}, nil
 }
 
-func doTransfer(client *backend.RxGrpcClient, f log.LogFile, moduleName, host string) {
+// TransferAndDelete transfers log file to journal service and removes it,
+// returned error means that file was not transferred
+func TransferAndDelete(client *backend.RxGrpcClient, f log.LogFile, moduleName, host string) error {
 	err := client.InvokeStream(transferMethod, -1, func(stream streaming.DuplexMessageStream, md metadata.MD) error {
 		return streaming.WriteFile(stream, f.FullPath, statToFileHeader(f, moduleName, host))
 	})
 
 	if err != nil {
 		logger.Errorf(codes.JournalingError, "could not transfer log file '%s': %v", f.FullPath, err)
-	} else if err := os.Remove(f.FullPath); err != nil {
+		return err
+	}
+	if err := os.Remove(f.FullPath); err != nil {
 		logger.Warnf(codes.JournalingError, "could not remove log file '%s': %v", f.FullPath, err)
 	} else {
 		logger.Debugf(0, "log '%s' successfully transferred", f.FullPath)
 	}
+	return nil
 }
 
 func statToFileHeader(f log.LogFile, moduleName, host string) streaming.BeginFile {
@@ -98,15 +105,16 @@ func statToFileHeader(f log.LogFile, moduleName, host string) streaming.BeginFil
 		moduleNameField: moduleName,
 		hostField:       host,
 		createdAtField:  entry.FormatTime(f.CreatedAt),
+		encryptedField:  f.IsEncrypted(),
 	}
-	ct := binaryContent
-	if f.Compressed {
-		ct = gzipContent
+	fileCodec := f.Codec
+	if fileCodec == "" && f.Compressed {
+		fileCodec = codec.Gzip
 	}
 	return streaming.BeginFile{
 		FileName:      f.Name(),
 		FormDataName:  f.Name(),
-		ContentType:   ct,
+		ContentType:   fileCodec.ContentType(),
 		ContentLength: f.Size(),
 		FormData:      formData,
 	}